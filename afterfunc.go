@@ -0,0 +1,10 @@
+//go:build go1.21
+
+package event
+
+import "context"
+
+// afterFunc call f in its own goroutine after ctx is done. see context.AfterFunc
+func afterFunc(ctx context.Context, f func()) (stop func() bool) {
+	return context.AfterFunc(ctx, f)
+}
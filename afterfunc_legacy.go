@@ -0,0 +1,10 @@
+//go:build !go1.21
+
+package event
+
+import "context"
+
+// afterFunc is not supported before go1.21, the listeners of done ctx are removed on dispatch.
+func afterFunc(context.Context, func()) (stop func() bool) {
+	return nil
+}
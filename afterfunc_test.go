@@ -0,0 +1,27 @@
+//go:build go1.21
+
+package event_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/gookit/goutil/testutil/assert"
+)
+
+func TestManager_OnCtx_autoRemove(t *testing.T) {
+	em := event.NewManager("test")
+	ctx, cancel := context.WithCancel(context.Background())
+	em.OnCtx(ctx, "evt", event.ListenerFunc(emptyListener))
+	em.OnceCtx(ctx, "evt", event.ListenerFunc(emptyListener))
+	assert.Eq(t, 2, em.ListenersCount("evt"))
+
+	// removed by the ctx callback, without dispatch
+	cancel()
+	for i := 0; i < 100 && em.HasListeners("evt"); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.False(t, em.HasListeners("evt"))
+}
@@ -1,6 +1,7 @@
 package event_test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/gookit/event"
@@ -47,3 +48,56 @@ func BenchmarkManager_Fire_wildcard(b *testing.B) {
 		_, _ = em.Fire("app.up", nil)
 	}
 }
+
+func BenchmarkManager_Fire_parallel(b *testing.B) {
+	em := event.NewManager("test", event.EnableLock(true))
+	for i := 0; i < 5; i++ {
+		em.On("app.up", event.ListenerFunc(func(e event.Event) error {
+			return nil
+		}), i)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = em.Fire("app.up", nil)
+		}
+	})
+}
+
+func BenchmarkManager_Fire_parallel_pathMode(b *testing.B) {
+	em := event.NewManager("test", event.UsePathMode, event.EnableLock(true))
+	em.On("app.**", event.ListenerFunc(func(e event.Event) error {
+		return nil
+	}))
+	em.On("app.*.up", event.ListenerFunc(func(e event.Event) error {
+		return nil
+	}))
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = em.Fire("app.db.up", nil)
+		}
+	})
+}
+
+func BenchmarkManager_Fire_pathMode_manyPatterns(b *testing.B) {
+	em := event.NewManager("test", event.UsePathMode)
+	for i := 0; i < 2000; i++ {
+		em.On(fmt.Sprintf("app%d.*.update", i), event.ListenerFunc(func(e event.Event) error {
+			return nil
+		}))
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_, _ = em.Fire("app1001.user.update", nil)
+	}
+}
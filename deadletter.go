@@ -0,0 +1,307 @@
+package event
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetterSink receive the events failed to handle. see Options.DeadLetterSink
+//
+// Events are put to the sink on:
+//   - the async handle failed, or failed to push to the async queue(attempts is 0).
+//   - the sync fire failed after retries. see RetryPolicy
+//
+// NOTE: Put is called on the consumer goroutines concurrently, it must be safe for concurrent use.
+type DeadLetterSink interface {
+	Put(e Event, err error, attempts int)
+}
+
+// DeadLetterSource the sink can take out the dead letters, for Manager.Redrive()
+type DeadLetterSource interface {
+	DeadLetterSink
+	// Drain take out all dead letters, they are removed from the sink.
+	Drain() ([]*DeadLetter, error)
+}
+
+// DeadLetter an event failed to handle
+type DeadLetter struct {
+	Event Event
+	// Err the last error of handle the event
+	Err error
+	// Attempts the number of calls to the failed listener. 0 for not handled.
+	Attempts int
+	// Time of the event put to the sink
+	Time time.Time
+}
+
+// WithDeadLetter set the dead-letter sink for the failed events. see DeadLetterSink
+//
+// Usage:
+//
+//	sink := event.NewMemoryDeadLetterSink()
+//	em := event.NewManager("app", event.WithDeadLetter(sink))
+func WithDeadLetter(sink DeadLetterSink) OptionFn {
+	return func(o *Options) {
+		o.DeadLetterSink = sink
+	}
+}
+
+// attemptsOf get the number of attempts from the handle error.
+func attemptsOf(err error) int {
+	var re *RetryError
+	if errors.As(err, &re) {
+		return re.Attempts
+	}
+	if err == ErrQueueFull || err == ErrManagerClosed {
+		return 0
+	}
+	return 1
+}
+
+// putDeadLetter put the failed event to the dead-letter sink, if has been set.
+func (em *Manager) putDeadLetter(e Event, err error) {
+	if em.DeadLetterSink != nil {
+		em.DeadLetterSink.Put(e, err, attemptsOf(err))
+	}
+}
+
+// Redrive take out the dead letters from the sink and fire them again, after the bug is fixed.
+//
+// The events are fired synchronously with the ctx, events failed again are put back to the sink.
+// Returns the number of the events handled successfully.
+//
+// The Options.DeadLetterSink must implement DeadLetterSource, eg: MemoryDeadLetterSink, FileDeadLetterSink
+func (em *Manager) Redrive(ctx context.Context) (int, error) {
+	src, ok := em.DeadLetterSink.(DeadLetterSource)
+	if !ok {
+		return 0, errors.New("event: the dead-letter sink is not set or not support redrive")
+	}
+
+	letters, err := src.Drain()
+	if err != nil {
+		return 0, err
+	}
+
+	policy := em.PanicPolicy
+	if policy == PanicPropagate {
+		policy = PanicStop
+	}
+
+	var n int
+	for i, dl := range letters {
+		if err := ctx.Err(); err != nil {
+			// put back the rest letters
+			for _, rest := range letters[i:] {
+				src.Put(rest.Event, rest.Err, rest.Attempts)
+			}
+			return n, err
+		}
+
+		if err := em.fireEventBy(withContext(ctx, dl.Event), policy); err != nil {
+			src.Put(dl.Event, err, attemptsOf(err))
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+/*************************************************************
+ * region Memory sink
+ *************************************************************/
+
+// MemoryDeadLetterSink the in-memory dead-letter sink.
+type MemoryDeadLetterSink struct {
+	mu      sync.Mutex
+	letters []*DeadLetter
+}
+
+// NewMemoryDeadLetterSink create an in-memory dead-letter sink
+func NewMemoryDeadLetterSink() *MemoryDeadLetterSink {
+	return &MemoryDeadLetterSink{}
+}
+
+// Put a failed event to the sink
+func (s *MemoryDeadLetterSink) Put(e Event, err error, attempts int) {
+	s.mu.Lock()
+	s.letters = append(s.letters, &DeadLetter{Event: e, Err: err, Attempts: attempts, Time: time.Now()})
+	s.mu.Unlock()
+}
+
+// Len get the number of dead letters
+func (s *MemoryDeadLetterSink) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.letters)
+}
+
+// Letters get a copy of the dead letters
+func (s *MemoryDeadLetterSink) Letters() []*DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*DeadLetter(nil), s.letters...)
+}
+
+// Drain take out all dead letters
+func (s *MemoryDeadLetterSink) Drain() ([]*DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letters := s.letters
+	s.letters = nil
+	return letters, nil
+}
+
+/*************************************************************
+ * region File sink
+ *************************************************************/
+
+// deadLetterLine a line of the FileDeadLetterSink file
+type deadLetterLine struct {
+	Name     string    `json:"name"`
+	Data     M         `json:"data,omitempty"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	Time     time.Time `json:"time"`
+	// Type the Go type of the event which cannot restore on Drain(). empty for *BasicEvent
+	Type string `json:"type,omitempty"`
+}
+
+// restorable check the event can be restored from the name and data.
+func restorable(e Event) bool {
+	if ce, ok := e.(*contextEvent); ok {
+		e = ce.Event
+	}
+	_, ok := e.(*BasicEvent)
+	return ok
+}
+
+// FileDeadLetterSink the dead-letter sink write the events to a file, one JSON per line.
+//
+// Only the event name and data are saved, the events are restored as *BasicEvent on Drain().
+// The data must be able to encode by encoding/json.
+//
+// Other events(eg: *TypedEvent) cannot be restored, Put still writes them to the file
+// but records an error(returned by Close), and Drain() keeps them in the file.
+type FileDeadLetterSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	// err the first error of write the file, returned by Close()
+	err error
+}
+
+// NewFileDeadLetterSink create a JSON-lines file dead-letter sink, the file is created if not exists.
+func NewFileDeadLetterSink(path string) (*FileDeadLetterSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileDeadLetterSink{path: path, file: f}, nil
+}
+
+// Put a failed event to the file
+func (s *FileDeadLetterSink) Put(e Event, err error, attempts int) {
+	line := deadLetterLine{
+		Name:     e.Name(),
+		Data:     e.Data(),
+		Attempts: attempts,
+		Time:     time.Now(),
+	}
+	if err != nil {
+		line.Error = err.Error()
+	}
+
+	var rerr error
+	if !restorable(e) {
+		line.Type = fmt.Sprintf("%T", e)
+		rerr = fmt.Errorf("event: dead letter %q of type %s cannot be restored from file", line.Name, line.Type)
+	}
+
+	bs, jerr := json.Marshal(line)
+	if jerr == nil {
+		bs = append(bs, '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if jerr == nil {
+		_, jerr = s.file.Write(bs)
+	}
+	if jerr == nil {
+		jerr = rerr
+	}
+	if jerr != nil && s.err == nil {
+		s.err = jerr
+	}
+}
+
+// Drain read out all dead letters, the file is truncated.
+//
+// The lines of events cannot be restored are kept in the file.
+func (s *FileDeadLetterSink) Drain() ([]*DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bs, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var letters []*DeadLetter
+	var kept []byte
+	sc := bufio.NewScanner(bytes.NewReader(bs))
+	sc.Buffer(make([]byte, 0, 64*1024), len(bs)+1)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+
+		var line deadLetterLine
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			return nil, err
+		}
+		if line.Type != "" {
+			kept = append(kept, sc.Bytes()...)
+			kept = append(kept, '\n')
+			continue
+		}
+
+		letters = append(letters, &DeadLetter{
+			Event:    New(line.Name, line.Data),
+			Err:      errors.New(line.Error),
+			Attempts: line.Attempts,
+			Time:     line.Time,
+		})
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.file.Truncate(0); err != nil {
+		return letters, err
+	}
+	if len(kept) > 0 {
+		_, err = s.file.Write(kept)
+	}
+	return letters, err
+}
+
+// Close the file. returns the first error of write the file, if has.
+func (s *FileDeadLetterSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	return s.err
+}
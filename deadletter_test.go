@@ -0,0 +1,168 @@
+package event_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/gookit/goutil/testutil/assert"
+)
+
+func TestManager_DeadLetter(t *testing.T) {
+	sink := event.NewMemoryDeadLetterSink()
+	em := event.NewManager("test", event.WithDeadLetter(sink))
+
+	var broken atomic.Bool
+	broken.Store(true)
+	var handled int32
+	em.On("order.paid", event.ListenerFunc(func(e event.Event) error {
+		if broken.Load() {
+			return errors.New("listener bug")
+		}
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}))
+
+	em.Async("order.paid", event.M{"id": 1})
+	em.Async("order.paid", event.M{"id": 2})
+	assert.Err(t, em.CloseWait())
+	assert.Eq(t, 2, sink.Len())
+
+	dl := sink.Letters()[0]
+	assert.Eq(t, "order.paid", dl.Event.Name())
+	assert.Eq(t, "listener bug", dl.Err.Error())
+	assert.Eq(t, 1, dl.Attempts)
+
+	// not dead-letter the sync fire error without retry
+	err, _ := em.Fire("order.paid", nil)
+	assert.Err(t, err)
+	assert.Eq(t, 2, sink.Len())
+
+	// redrive after the bug fixed
+	broken.Store(false)
+	n, err := em.Redrive(context.Background())
+	assert.NoErr(t, err)
+	assert.Eq(t, 2, n)
+	assert.Eq(t, int32(2), handled)
+	assert.Eq(t, 0, sink.Len())
+}
+
+func TestManager_DeadLetter_retry(t *testing.T) {
+	sink := event.NewMemoryDeadLetterSink()
+	em := event.NewManager("test",
+		event.WithDeadLetter(sink),
+		event.WithRetryPolicy(event.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+	)
+	em.On("evt", event.ListenerFunc(func(e event.Event) error {
+		return errors.New("always fail")
+	}))
+
+	err, _ := em.Fire("evt", nil)
+	assert.Err(t, err)
+	assert.Eq(t, 1, sink.Len())
+	assert.Eq(t, 2, sink.Letters()[0].Attempts)
+
+	// failed again, put back
+	n, err := em.Redrive(context.Background())
+	assert.NoErr(t, err)
+	assert.Eq(t, 0, n)
+	assert.Eq(t, 1, sink.Len())
+
+	// canceled, keep the letters
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n, err = em.Redrive(ctx)
+	assert.ErrIs(t, err, context.Canceled)
+	assert.Eq(t, 0, n)
+	assert.Eq(t, 1, sink.Len())
+
+	// sink not set
+	_, err = event.NewManager("test").Redrive(context.Background())
+	assert.Err(t, err)
+}
+
+func TestManager_DeadLetter_publish(t *testing.T) {
+	sink := event.NewMemoryDeadLetterSink()
+	em := event.NewManager("test",
+		event.WithDeadLetter(sink),
+		event.WithRetryPolicy(event.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+	)
+	event.Handle(em, func(ctx context.Context, v userCreated) error {
+		return errors.New("always fail")
+	})
+
+	assert.Err(t, em.Publish(userCreated{ID: 23}))
+	assert.Eq(t, 1, sink.Len())
+	assert.Eq(t, 2, sink.Letters()[0].Attempts)
+	assert.Eq(t, userCreated{ID: 23}, sink.Letters()[0].Event.(event.PayloadAble[any]).Payload())
+}
+
+func TestFileDeadLetterSink(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	sink, err := event.NewFileDeadLetterSink(file)
+	assert.NoErr(t, err)
+
+	sink.Put(event.New("order.paid", event.M{"id": "1001"}), errors.New("listener bug"), 3)
+	sink.Put(event.New("order.created", nil), event.ErrQueueFull, 0)
+
+	letters, err := sink.Drain()
+	assert.NoErr(t, err)
+	assert.Len(t, letters, 2)
+	assert.Eq(t, "order.paid", letters[0].Event.Name())
+	assert.Eq(t, "1001", letters[0].Event.Get("id"))
+	assert.Eq(t, "listener bug", letters[0].Err.Error())
+	assert.Eq(t, 3, letters[0].Attempts)
+	assert.Eq(t, "order.created", letters[1].Event.Name())
+
+	// drained
+	letters, err = sink.Drain()
+	assert.NoErr(t, err)
+	assert.Empty(t, letters)
+
+	// redrive by manager
+	em := event.NewManager("test", event.WithDeadLetter(sink))
+	var ids []any
+	em.On("order.paid", event.ListenerFunc(func(e event.Event) error {
+		ids = append(ids, e.Get("id"))
+		return nil
+	}))
+
+	sink.Put(event.New("order.paid", event.M{"id": "1002"}), errors.New("listener bug"), 1)
+	n, err := em.Redrive(context.Background())
+	assert.NoErr(t, err)
+	assert.Eq(t, 1, n)
+	assert.Eq(t, []any{"1002"}, ids)
+	assert.NoErr(t, sink.Close())
+}
+
+func TestFileDeadLetterSink_typed(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	sink, err := event.NewFileDeadLetterSink(file)
+	assert.NoErr(t, err)
+
+	sink.Put(event.NewTyped("user.created", userCreated{ID: 23}), errors.New("listener bug"), 1)
+	sink.Put(event.New("order.paid", event.M{"id": "1001"}), errors.New("listener bug"), 1)
+
+	// typed event is kept in the file
+	letters, err := sink.Drain()
+	assert.NoErr(t, err)
+	assert.Len(t, letters, 1)
+	assert.Eq(t, "order.paid", letters[0].Event.Name())
+
+	bs, err := os.ReadFile(file)
+	assert.NoErr(t, err)
+	assert.StrContains(t, string(bs), `"name":"user.created"`)
+	assert.StrContains(t, string(bs), `"type":"*event.TypedEvent[`)
+
+	letters, err = sink.Drain()
+	assert.NoErr(t, err)
+	assert.Empty(t, letters)
+
+	err = sink.Close()
+	assert.ErrSubMsg(t, err, `dead letter "user.created" of type *event.TypedEvent`)
+}
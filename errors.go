@@ -0,0 +1,103 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// There are errors on fire events by channel.
+var (
+	// ErrQueueFull the async event queue is full.
+	ErrQueueFull = errors.New("event: the async event queue is full")
+	// ErrManagerClosed the manager is closed, deny to fire new async event.
+	ErrManagerClosed = errors.New("event: the manager is closed")
+)
+
+// AsyncError the error of handle an event fired by channel. eg: FireAsync(), Async()
+type AsyncError struct {
+	// Name of the event
+	Name string
+	// Event the handled event
+	Event Event
+	// Err the listener error or recovered panic
+	Err error
+}
+
+// Error message of the async error
+func (e *AsyncError) Error() string {
+	return fmt.Sprintf("event: async handle event %q error: %v", e.Name, e.Err)
+}
+
+// Unwrap get the listener error
+func (e *AsyncError) Unwrap() error { return e.Err }
+
+// ListenerPanicError the panic recovered from a listener. see Options.PanicPolicy
+type ListenerPanicError struct {
+	// Name of the event
+	Name string
+	// Listener the panicked listener
+	Listener Listener
+	// ListenerName the listener identity. func name for func listener, otherwise the type name.
+	ListenerName string
+	// Value the recovered panic value
+	Value any
+	// Stack trace of the panic
+	Stack []byte
+}
+
+func newListenerPanicError(name string, listener Listener, val any) *ListenerPanicError {
+	return &ListenerPanicError{
+		Name:         name,
+		Listener:     listener,
+		ListenerName: listenerName(listener),
+		Value:        val,
+		Stack:        debug.Stack(),
+	}
+}
+
+// Error message of the panic error
+func (e *ListenerPanicError) Error() string {
+	return fmt.Sprintf("event: listener %s panic on event %q: %v", e.ListenerName, e.Name, e.Value)
+}
+
+// Unwrap get the panic value if it is an error
+func (e *ListenerPanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// joinError multi errors, like the errors.Join() on go1.20+
+type joinError struct {
+	errs []error
+}
+
+// joinErrors join the errors, nil errors are discarded. returns nil if no error.
+func joinErrors(errs ...error) error {
+	var es []error
+	for _, err := range errs {
+		if err != nil {
+			es = append(es, err)
+		}
+	}
+
+	if len(es) == 0 {
+		return nil
+	}
+	return &joinError{errs: es}
+}
+
+// Error messages of the errors, separated by newline.
+func (e *joinError) Error() string {
+	ss := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		ss[i] = err.Error()
+	}
+	return strings.Join(ss, "\n")
+}
+
+// Unwrap returns the errors, errors.Is() and errors.As() can check them on go1.20+
+func (e *joinError) Unwrap() []error { return e.errs }
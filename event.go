@@ -3,6 +3,7 @@ package event
 
 import (
 	"context"
+	"time"
 )
 
 // wildcard event name
@@ -23,16 +24,50 @@ const (
 	// ModePath path mode.
 	//
 	//  - "*" matches any sequence of non . characters (like at path.Match())
-	//  - "**" match all characters to end, only allow at start or end on pattern.
+	//  - "**" match one or more segments at start or end on pattern,
+	//    match zero or more segments in the middle of pattern.
+	//
+	// Listeners of all matched patterns are called in one sequence by priority,
+	// listeners with the same priority are called in the registration order.
 	//
 	// Support like this:
 	// 	"eve.some.*.*"       -> match "eve.some.thing.run" "eve.some.thing.do"
 	// 	"eve.some.*.run"     -> match "eve.some.thing.run", but not match "eve.some.thing.do"
 	// 	"eve.some.**"        -> match any start with "eve.some.". eg: "eve.some.thing.run" "eve.some.thing.do"
 	// 	"**.thing.run"       -> match any ends with ".thing.run". eg: "eve.some.thing.run"
+	// 	"eve.**.run"         -> match "eve.run" "eve.some.run" "eve.some.thing.run"
 	ModePath
 )
 
+// There are panic policies for the listener panics. see Options.PanicPolicy
+const (
+	// PanicPropagate not recover the listener panic, it will propagate to the caller.
+	//
+	// NOTE: on consume the events fired by channel, will use PanicStop instead.
+	PanicPropagate uint8 = iota
+	// PanicStop recover the listener panic and stop calling the rest listeners,
+	// returns it as a *ListenerPanicError.
+	PanicStop
+	// PanicContinue recover the listener panic and continue calling the rest listeners,
+	// returns all *ListenerPanicError joined with the final error.
+	PanicContinue
+)
+
+// There are overflow policies on the async event queue is full. see Options.Overflow
+const (
+	// OverflowBlock block the caller until the queue has space. default policy.
+	OverflowBlock uint8 = iota
+	// OverflowBlockTimeout block the caller until the queue has space or Options.BlockTimeout,
+	// the event will be dropped on timeout.
+	OverflowBlockTimeout
+	// OverflowDropNewest drop the new event.
+	OverflowDropNewest
+	// OverflowDropOldest drop the oldest event in the queue, then push the new event.
+	OverflowDropOldest
+	// OverflowError drop the new event and report ErrQueueFull. see Options.AsyncErrorHandler
+	OverflowError
+)
+
 // M is short name for map[string]...
 type M = map[string]any
 
@@ -49,13 +84,50 @@ type ManagerFace interface {
 // Options event manager config options
 type Options struct {
 	// EnableLock enable lock on fire event. default is False.
+	//
+	// Deprecated: fire event always reads the goroutine-safe listener snapshots
+	// and never holds a lock while calling listeners. the option has no effect now.
 	EnableLock bool
 	// ChannelSize for fire events by goroutine. default: 100
 	ChannelSize int
 	// ConsumerNum for fire events by goroutine. default: 3
 	ConsumerNum int
+	// Overflow the policy on the async event queue is full. default is OverflowBlock
+	Overflow uint8
+	// BlockTimeout the max wait time for OverflowBlockTimeout
+	BlockTimeout time.Duration
+	// PriorityQueue use the heap-backed priority queue for async events, instead of the FIFO channel.
+	//
+	// Higher priority events are consumed first, see Prioritized and Manager.FireAsyncPriority().
+	// NOTE: the events with partition key are still consumed in FIFO order.
+	PriorityQueue bool
+	// PriorityAging the starvation protection of the priority queue,
+	// the priority of a waiting event grows 1 for every aging duration. default: 10ms, negative for disable.
+	PriorityAging time.Duration
+	// Lanes the dedicated async lanes, events not matched any lane will use the default lane.
+	//
+	// The default lane is configured by ChannelSize, ConsumerNum, Overflow and BlockTimeout. see WithLane()
+	Lanes []Lane
 	// MatchMode event name match mode. default is ModeSimple
 	MatchMode uint8
+	// Matcher custom event name matcher. if set, will ignore the MatchMode.
+	//
+	// Built-in: NewSimpleMatcher(), NewPathMatcher(), NewRegexMatcher(), NewMQTTMatcher()
+	Matcher Matcher
+	// PanicPolicy the policy on a listener panic. default is PanicPropagate
+	PanicPolicy uint8
+	// RetryPolicy the retry policy for all listeners. nil for no retry.
+	//
+	// The listeners wrapped by WithRetry() use their own policy.
+	RetryPolicy *RetryPolicy
+	// DeadLetterSink receive the events failed to handle. see DeadLetterSink
+	DeadLetterSink DeadLetterSink
+	// Clock the func get the current time, for the listener active window. default is time.Now
+	Clock func() time.Time
+	// AsyncErrorHandler handle the listener errors and recovered panics on consume the events fired by channel.
+	//
+	// The err is an *AsyncError, it will be called on the consumer goroutines concurrently.
+	AsyncErrorHandler func(e Event, err error)
 }
 
 // OptionFn event manager config option func
@@ -78,7 +150,64 @@ func WithConsumerNum(num int) OptionFn {
 	}
 }
 
+// OnAsyncError set the handler for the errors on consume the events fired by channel.
+//
+// Usage:
+//
+//	em := NewManager("test", OnAsyncError(func(e Event, err error) {
+//		log.Println(err)
+//	}))
+func OnAsyncError(fn func(e Event, err error)) OptionFn {
+	return func(o *Options) {
+		o.AsyncErrorHandler = fn
+	}
+}
+
+// WithPanicPolicy set the policy on a listener panic. see PanicStop, PanicContinue
+func WithPanicPolicy(policy uint8) OptionFn {
+	return func(o *Options) {
+		o.PanicPolicy = policy
+	}
+}
+
+// WithOverflow set the policy on the async event queue is full.
+//
+// see OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowError
+func WithOverflow(policy uint8) OptionFn {
+	return func(o *Options) {
+		o.Overflow = policy
+	}
+}
+
+// WithBlockTimeout set the OverflowBlockTimeout policy, wait for the queue has space until timeout.
+func WithBlockTimeout(timeout time.Duration) OptionFn {
+	return func(o *Options) {
+		o.Overflow = OverflowBlockTimeout
+		o.BlockTimeout = timeout
+	}
+}
+
+// UsePriorityQueue use the priority queue for async events, with the aging duration for starvation protection.
+// aging 0 for use default, negative for disable.
+//
+// see Options.PriorityQueue, Options.PriorityAging
+func UsePriorityQueue(aging time.Duration) OptionFn {
+	return func(o *Options) {
+		o.PriorityQueue = true
+		o.PriorityAging = aging
+	}
+}
+
+// WithRetryPolicy set the retry policy for all listeners. see RetryPolicy
+func WithRetryPolicy(policy RetryPolicy) OptionFn {
+	return func(o *Options) {
+		o.RetryPolicy = &policy
+	}
+}
+
 // EnableLock enable lock on fire event.
+//
+// Deprecated: the option has no effect now, see Options.EnableLock
 func EnableLock(enable bool) OptionFn {
 	return func(o *Options) {
 		o.EnableLock = enable
@@ -118,6 +247,40 @@ type ContextAble interface {
 	WithContext(ctx context.Context)
 }
 
+// Partitioned the event has a partition key for async fire.
+// events with same key are handled sequentially by the same consumer. see Manager.FireAsyncKey()
+type Partitioned interface {
+	PartitionKey() string
+}
+
+// Prioritized the event has a priority for async fire on the priority queue.
+// higher priority events are consumed first. see Options.PriorityQueue
+type Prioritized interface {
+	Priority() int
+}
+
+// ParamsAble the event can carry the named params captured by the listen pattern.
+//
+// eg: listen "tenant.{tenant}.order.{action}", fire "tenant.t1.order.paid"
+// will got params: {"tenant": "t1", "action": "paid"}
+type ParamsAble interface {
+	Params() map[string]string
+	SetParams(params map[string]string)
+}
+
+// PathParams get the named params captured by the listen pattern of current listener.
+// returns nil if the event is not ParamsAble or no named params.
+//
+// Usage in listener:
+//
+//	tenant := event.PathParams(e)["tenant"]
+func PathParams(e Event) map[string]string {
+	if pe, ok := e.(ParamsAble); ok {
+		return pe.Params()
+	}
+	return nil
+}
+
 // FactoryFunc for create event instance.
 type FactoryFunc func() Event
 
@@ -131,6 +294,8 @@ type BasicEvent struct {
 	target any
 	// mark is aborted
 	aborted bool
+	// named params captured by the listen pattern
+	params map[string]string
 }
 
 // New create an event instance
@@ -216,6 +381,12 @@ func (e *BasicEvent) SetData(data M) Event {
 	return e
 }
 
+// Params get the named params captured by the listen pattern of current listener.
+func (e *BasicEvent) Params() map[string]string { return e.params }
+
+// SetParams set the named params, it is called by manager before call each listener.
+func (e *BasicEvent) SetParams(params map[string]string) { e.params = params }
+
 // SetTarget set event target
 func (e *BasicEvent) SetTarget(target any) *BasicEvent {
 	e.target = target
@@ -250,3 +421,13 @@ type contextEvent struct {
 func newContextEvent(ctx context.Context, e Event) ContextAble {
 	return &contextEvent{Event: e, ContextTrait: ContextTrait{ctx: ctx}}
 }
+
+// Params get the named params from the wrapped event.
+func (ce *contextEvent) Params() map[string]string { return PathParams(ce.Event) }
+
+// SetParams set the named params to the wrapped event.
+func (ce *contextEvent) SetParams(params map[string]string) {
+	if pe, ok := ce.Event.(ParamsAble); ok {
+		pe.SetParams(params)
+	}
+}
@@ -0,0 +1,101 @@
+package event
+
+import (
+	"time"
+)
+
+// the min interval of the lazy sweep for expired listeners
+const sweepInterval = time.Second
+
+// WithClock set the clock func for the listener active window. default is time.Now
+//
+// Usage:
+//
+//	em := event.NewManager("test", event.WithClock(func() time.Time { return fakeNow }))
+func WithClock(now func() time.Time) OptionFn {
+	return func(o *Options) {
+		o.Clock = now
+	}
+}
+
+// ActiveFrom the listener is active from the time, it is skipped before it.
+func ActiveFrom(t time.Time) ListenOption {
+	return func(o *listenOptions) {
+		o.from = t
+	}
+}
+
+// ActiveUntil the listener is active until the time, it is removed after expired.
+func ActiveUntil(t time.Time) ListenOption {
+	return func(o *listenOptions) {
+		o.until = t
+	}
+}
+
+// TTL the listener expires after the duration from registration. see ActiveUntil()
+func TTL(d time.Duration) ListenOption {
+	return func(o *listenOptions) {
+		o.ttl = d
+	}
+}
+
+// now get the current time by the Options.Clock
+func (em *Manager) now() time.Time {
+	if em.Clock != nil {
+		return em.Clock()
+	}
+	return time.Now()
+}
+
+// applyWindow set the active window of the listener item by the options.
+func (em *Manager) applyWindow(li *ListenerItem, o *listenOptions) {
+	li.activeFrom, li.activeUntil = o.from, o.until
+	if o.ttl > 0 {
+		if until := em.now().Add(o.ttl); li.activeUntil.IsZero() || until.Before(li.activeUntil) {
+			li.activeUntil = until
+		}
+	}
+
+	if !li.activeUntil.IsZero() {
+		em.windowed.Store(true)
+	}
+}
+
+// SweepExpired remove all expired listeners, and the listeners of done ctx(see OnCtx()).
+// returns the number of removed listeners.
+//
+// The expired listeners are also removed on dispatch, and by a lazy sweep on fire event.
+// you can call it in a background ticker if the events are rarely fired.
+func (em *Manager) SweepExpired() int {
+	now := em.now()
+	em.nextSweep.Store(now.Add(sweepInterval).UnixNano())
+
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	var expired []*ListenerItem
+	for _, lq := range em.listeners {
+		for _, li := range lq.items {
+			if li.expiredAt(now) || li.expired() {
+				expired = append(expired, li)
+			}
+		}
+	}
+
+	for _, li := range expired {
+		em.removeItemLocked(li)
+	}
+	return len(expired)
+}
+
+// lazySweep sweep the expired listeners at most once per sweepInterval.
+func (em *Manager) lazySweep() {
+	if !em.windowed.Load() {
+		return
+	}
+
+	next := em.nextSweep.Load()
+	if em.now().UnixNano() >= next && em.nextSweep.CompareAndSwap(next, next+1) {
+		em.SweepExpired()
+	}
+}
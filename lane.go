@@ -0,0 +1,372 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLane the name of the default async lane, for the events not matched any lane.
+const DefaultLane = "default"
+
+// Lane the config of a dedicated async lane. events matched the lane patterns
+// are pushed to its own queue and handled by its own consumers.
+//
+// Zero values of the config will use the default values. eg: ChannelSize=100, ConsumerNum=3
+type Lane struct {
+	// Name of the lane, must be unique.
+	Name string
+	// Patterns the event name patterns of the lane, matched by the rules of the manager Matcher.
+	// the ModePath rules are used if the Matcher is not set.
+	//
+	// eg: "payment.*", "metrics.**", MQTT "sensors/#"
+	Patterns []string
+	// ChannelSize the queue size of the lane.
+	ChannelSize int
+	// ConsumerNum the number of consumers of the lane.
+	ConsumerNum int
+	// Overflow the policy on the queue of lane is full. see Options.Overflow
+	Overflow uint8
+	// BlockTimeout the max wait time for OverflowBlockTimeout
+	BlockTimeout time.Duration
+	// PriorityQueue use the priority queue for the lane. see Options.PriorityQueue
+	PriorityQueue bool
+	// PriorityAging the aging duration of the priority queue. see Options.PriorityAging
+	PriorityAging time.Duration
+}
+
+// WithLane add a dedicated async lane. see Lane
+//
+// If an event name matched multi lanes, the first added lane will be used.
+//
+// Usage:
+//
+//	em := NewManager("app", WithLane(Lane{
+//		Name:        "payment",
+//		Patterns:    []string{"payment.*"},
+//		ConsumerNum: 5,
+//	}))
+func WithLane(lane Lane) OptionFn {
+	return func(o *Options) {
+		o.Lanes = append(o.Lanes, lane)
+	}
+}
+
+// asyncLane the async queue and consumers of a lane.
+type asyncLane struct {
+	name string
+	// config of the lane, the default lane points to the manager Options.
+	channelSize  *int
+	consumerNum  *int
+	overflow     *uint8
+	blockTimeout *time.Duration
+	priority     *bool
+	aging        *time.Duration
+
+	// qmu guards create or replace the queue
+	qmu sync.Mutex
+	// q the queue of the lane, created on first use.
+	q atomic.Pointer[eventQueue]
+	// dropped the counter of dropped events. see Options.Overflow
+	dropped atomic.Uint64
+}
+
+// newDefaultLane create the default lane, the config points to the manager Options.
+func newDefaultLane(opt *Options) *asyncLane {
+	return &asyncLane{
+		name:         DefaultLane,
+		channelSize:  &opt.ChannelSize,
+		consumerNum:  &opt.ConsumerNum,
+		overflow:     &opt.Overflow,
+		blockTimeout: &opt.BlockTimeout,
+		priority:     &opt.PriorityQueue,
+		aging:        &opt.PriorityAging,
+	}
+}
+
+// newNamedLane create a named lane, the lane config is copied.
+func newNamedLane(lane Lane) *asyncLane {
+	cfg := &lane
+	return &asyncLane{
+		name:         cfg.Name,
+		channelSize:  &cfg.ChannelSize,
+		consumerNum:  &cfg.ConsumerNum,
+		overflow:     &cfg.Overflow,
+		blockTimeout: &cfg.BlockTimeout,
+		priority:     &cfg.PriorityQueue,
+		aging:        &cfg.PriorityAging,
+	}
+}
+
+// queue get the queue of lane, will make it and consumers on first use.
+func (l *asyncLane) queue(em *Manager) *eventQueue {
+	if q := l.q.Load(); q != nil {
+		return q
+	}
+
+	l.qmu.Lock()
+	defer l.qmu.Unlock()
+	if q := l.q.Load(); q != nil {
+		return q
+	}
+	return l.makeConsumers(em, nil)
+}
+
+// push the event to the queue of lane. if try is true, never block the caller.
+func (l *asyncLane) push(em *Manager, key string, priority int, e Event, try bool) error {
+	policy := *l.overflow
+	if try && policy != OverflowDropOldest {
+		policy = OverflowError
+	}
+
+	for {
+		q := l.queue(em)
+		err := q.push(key, priority, e, policy, *l.blockTimeout)
+		// the queue is replaced by SetConsumerNum() or Restart(), push to the new one.
+		if err == ErrManagerClosed && l.q.Load() != q {
+			continue
+		}
+		return err
+	}
+}
+
+// makeConsumers make a new queue and consumers, must be called with l.qmu locked.
+//
+// if prev is not nil, the new consumers will start after the consumers of prev exit,
+// so the events with same partition key are still handled in order.
+func (l *asyncLane) makeConsumers(em *Manager, prev *eventQueue) *eventQueue {
+	if *l.consumerNum <= 0 {
+		*l.consumerNum = defaultConsumerNum
+	}
+	if *l.channelSize <= 0 {
+		*l.channelSize = defaultChannelSize
+	}
+
+	var q *eventQueue
+	if *l.priority {
+		if *l.aging == 0 {
+			*l.aging = defaultPriorityAging
+		}
+		q = newPriorityEventQueue(*l.channelSize, *l.consumerNum, *l.aging, &l.dropped)
+	} else {
+		q = newEventQueue(*l.channelSize, *l.consumerNum, &l.dropped)
+	}
+	l.q.Store(q)
+
+	// make event consumers
+	for i := 0; i < *l.consumerNum; i++ {
+		em.wg.Add(1)
+		q.workers.Add(1)
+
+		go func(keyed chan Event) {
+			defer func() {
+				q.workers.Done()
+				em.wg.Done()
+			}()
+
+			if prev != nil {
+				prev.workers.Wait()
+			}
+			em.consume(q, keyed)
+		}(q.keyed[i])
+	}
+	return q
+}
+
+// setConsumerNum set the number of consumers, switch to a new queue if running.
+func (l *asyncLane) setConsumerNum(em *Manager, n int) {
+	l.qmu.Lock()
+	defer l.qmu.Unlock()
+
+	*l.consumerNum = n
+	if old := l.q.Load(); old != nil && !old.isClosed() {
+		l.replaceQueue(em, old)
+	}
+}
+
+// restart the consumers with a new queue. do nothing if the lane is not used.
+func (l *asyncLane) restart(em *Manager) {
+	l.qmu.Lock()
+	defer l.qmu.Unlock()
+
+	if old := l.q.Load(); old != nil {
+		l.replaceQueue(em, old)
+	}
+}
+
+// replaceQueue switch to a new queue and consumers, must be called with l.qmu locked.
+//
+// The new queue is stored before the old one is closed, so the producers blocked
+// on the old queue are woken up and push the events to the new queue.
+func (l *asyncLane) replaceQueue(em *Manager, old *eventQueue) {
+	l.makeConsumers(em, old)
+	old.close()
+}
+
+// close the queue of lane. if the lane is not used, mark it closed without consumers.
+func (l *asyncLane) close() *eventQueue {
+	l.qmu.Lock()
+	defer l.qmu.Unlock()
+
+	q := l.q.Load()
+	if q == nil {
+		q = newEventQueue(1, 1, &l.dropped)
+		l.q.Store(q)
+	}
+	q.close()
+	return q
+}
+
+// reset close the queue and clear the state, the lane config is kept.
+func (l *asyncLane) reset() {
+	l.qmu.Lock()
+	if q := l.q.Swap(nil); q != nil {
+		q.close()
+	}
+	l.qmu.Unlock()
+	l.dropped.Store(0)
+}
+
+// laneIndex the immutable index of the named lanes, find the lane by event name.
+type laneIndex struct {
+	lanes []*asyncLane
+	// m the matcher index the lane patterns, same kind as the manager Matcher.
+	m Matcher
+	// pattern to the index of lanes
+	patterns map[string]int
+}
+
+// match find the first added lane matched the event name. returns nil if not matched.
+func (li *laneIndex) match(name string) *asyncLane {
+	idx := -1
+	for _, pattern := range li.m.Match(name) {
+		if i, ok := li.patterns[pattern]; ok && (idx < 0 || i < idx) {
+			idx = i
+		}
+	}
+
+	if idx < 0 {
+		return nil
+	}
+	return li.lanes[idx]
+}
+
+// initLanes build the lane index by the Options.Lanes. will panic on invalid lane config.
+func (em *Manager) initLanes() {
+	old := em.laneIdx.Load()
+	if old == nil && len(em.Lanes) == 0 {
+		return
+	}
+
+	li := &laneIndex{m: newLaneMatcher(em.Matcher), patterns: make(map[string]int)}
+	for _, lane := range em.Lanes {
+		if lane.Name == "" || lane.Name == DefaultLane {
+			panicf("event: the lane name cannot be empty or %q", DefaultLane)
+		}
+
+		for _, l := range li.lanes {
+			if l.name == lane.Name {
+				panicf("event: the lane %q is duplicated", lane.Name)
+			}
+		}
+
+		var al *asyncLane
+		// keep the lanes has been created
+		if old != nil {
+			for _, l := range old.lanes {
+				if l.name == lane.Name {
+					al = l
+					break
+				}
+			}
+		}
+		if al == nil {
+			al = newNamedLane(lane)
+		}
+
+		idx := len(li.lanes)
+		li.lanes = append(li.lanes, al)
+
+		for _, pattern := range lane.Patterns {
+			pattern, err := li.m.CheckName(pattern, true)
+			if err != nil {
+				panic(err)
+			}
+			if _, ok := li.patterns[pattern]; !ok {
+				li.patterns[pattern] = idx
+				li.m.Add(pattern)
+			}
+		}
+	}
+	em.laneIdx.Store(li)
+}
+
+// newLaneMatcher create a new matcher of the same kind as the manager Matcher, for index the lane patterns.
+//
+// The ModePath matcher is used if the Matcher is not set or it is a custom matcher.
+func newLaneMatcher(m Matcher) Matcher {
+	switch m.(type) {
+	case *mqttMatcher:
+		return NewMQTTMatcher()
+	case *regexMatcher:
+		return NewRegexMatcher()
+	}
+	return NewPathMatcher()
+}
+
+// laneOf get the lane for the event name. returns the default lane if not matched.
+func (em *Manager) laneOf(name string) *asyncLane {
+	if li := em.laneIdx.Load(); li != nil {
+		if l := li.match(name); l != nil {
+			return l
+		}
+	}
+	return em.lane
+}
+
+// allLanes get the default lane and all named lanes.
+func (em *Manager) allLanes() []*asyncLane {
+	ls := []*asyncLane{em.lane}
+	if li := em.laneIdx.Load(); li != nil {
+		ls = append(ls, li.lanes...)
+	}
+	return ls
+}
+
+// findLane get the lane by name. returns nil if not found.
+func (em *Manager) findLane(name string) *asyncLane {
+	for _, l := range em.allLanes() {
+		if l.name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// shutdownLanes close all lanes and wait for the queued events consumed, until the context done.
+func (em *Manager) shutdownLanes(ctx context.Context) (int, error) {
+	var qs []*eventQueue
+	for _, l := range em.allLanes() {
+		qs = append(qs, l.close())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for _, q := range qs {
+			q.workers.Wait()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return 0, nil
+	case <-ctx.Done():
+		var n int
+		for _, q := range qs {
+			n += q.stop()
+		}
+		return n, ctx.Err()
+	}
+}
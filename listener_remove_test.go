@@ -1,6 +1,7 @@
 package event
 
 import (
+	"context"
 	"testing"
 
 	"github.com/gookit/goutil/testutil/assert"
@@ -148,3 +149,65 @@ func testFuncCalc2(_ Event) error {
 }
 
 // /
+
+func Test_SweepExpired_ctx(t *testing.T) {
+	em := NewManager("test")
+
+	// the ctx item without auto remove, like on the go1.19 build.
+	ctx, cancel := context.WithCancel(context.Background())
+	li := &ListenerItem{Listener: ListenerFunc(testFuncCalc1), ctx: ctx}
+	em.addListenerItem("ev1", li)
+	assert.Equal(t, 0, em.SweepExpired())
+
+	cancel()
+	assert.Equal(t, 1, em.SweepExpired())
+	assert.False(t, em.HasListeners("ev1"))
+}
+
+func Test_RemoveListener_stopCtx(t *testing.T) {
+	em := NewManager("test")
+
+	var stopped int
+	addItem := func(name string, l Listener) {
+		em.addListenerItem(name, &ListenerItem{Listener: l, stop: func() bool {
+			stopped++
+			return true
+		}})
+	}
+
+	l1, l2 := ListenerFunc(testFuncCalc1), ListenerFunc(testFuncCalc2)
+	addItem("ev1", l1)
+	addItem("ev1", l2)
+	addItem("ev2", l1)
+	addItem("ev3", l2)
+
+	em.RemoveListener("ev1", l1)
+	assert.Equal(t, 1, stopped)
+	em.RemoveListener("", l1)
+	assert.Equal(t, 2, stopped)
+	em.RemoveListeners("ev1")
+	assert.Equal(t, 3, stopped)
+	em.Reset()
+	assert.Equal(t, 4, stopped)
+}
+
+func Test_ListenerItem_copy(t *testing.T) {
+	em := NewManager("test")
+
+	var calls int
+	em.Times("ev1", 1, ListenerFunc(func(e Event) error {
+		calls++
+		return nil
+	}))
+
+	// the copy of a registered item gets its own state on register
+	cp := *em.ListenersByName("ev1").Items()[0]
+	em.addListenerItem("ev2", &cp)
+
+	em.MustFire("ev1", nil)
+	em.MustFire("ev1", nil)
+	em.MustFire("ev2", nil)
+	assert.Equal(t, 2, calls)
+	assert.False(t, em.HasListeners("ev1"))
+	assert.False(t, em.HasListeners("ev2"))
+}
@@ -3,22 +3,40 @@ package event
 import (
 	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	defaultChannelSize = 100
 	defaultConsumerNum = 3
+	// default aging duration of the priority queue
+	defaultPriorityAging = 10 * time.Millisecond
+	// the max number of async errors kept for Wait()
+	maxAsyncErrors = 100
 )
 
 // Manager event manager definition. for manage events and listeners
 type Manager struct {
 	Options
+	// Deprecated: the manager no longer locks it on fire event, keep for compatible.
 	sync.Mutex
 
-	wg  sync.WaitGroup
-	ch  chan Event
-	oc  sync.Once
-	err error // latest error
+	wg sync.WaitGroup
+	// lane the default async lane, use the ChannelSize, ConsumerNum of Options.
+	lane *asyncLane
+	// laneIdx the index of named async lanes. see Options.Lanes
+	laneIdx atomic.Pointer[laneIndex]
+
+	// errMu guards the errs and errOmitted
+	errMu sync.Mutex
+	// errs the errors on consume the events fired by channel. see maxAsyncErrors
+	errs []error
+	// errOmitted the number of errors not kept in errs by the limit.
+	errOmitted int
+
+	// mu guards the listeners, listenedNames and eventFc registry.
+	mu sync.RWMutex
 
 	// name of the manager
 	name string
@@ -35,6 +53,19 @@ type Manager struct {
 	listeners map[string]*ListenerQueue
 	// storage all event names by listened
 	listenedNames map[string]int
+	// pathM built-in ModePath matcher, always index the listened names.
+	// so the MatchMode can be changed after listeners added.
+	pathM *pathMatcher
+	// storage the listeners registered by Handle(), keyed by the Go type.
+	typeListeners map[reflect.Type]*ListenerQueue
+	// seq the listener registration sequence
+	seq uint64
+	// windowed mark has listener with expiry or ctx(before go1.21), for the lazy sweep.
+	windowed atomic.Bool
+	// nextSweep the unix nano time of the next lazy sweep
+	nextSweep atomic.Int64
+	// mws the global middlewares, copy-on-write. see Use()
+	mws atomic.Pointer[[]Middleware]
 }
 
 // NewM create event manager. alias of the NewManager()
@@ -54,12 +85,15 @@ func NewManager(name string, fns ...OptionFn) *Manager {
 		// listeners
 		listeners:     make(map[string]*ListenerQueue),
 		listenedNames: make(map[string]int),
+		pathM:         &pathMatcher{trie: newPatternTrie(".")},
+		typeListeners: make(map[reflect.Type]*ListenerQueue),
 	}
 
 	// em.EnableLock = true
 	// for async fire by goroutine
 	em.ConsumerNum = defaultConsumerNum
 	em.ChannelSize = defaultChannelSize
+	em.lane = newDefaultLane(&em.Options)
 
 	// apply options
 	return em.WithOptions(fns...)
@@ -70,6 +104,16 @@ func (em *Manager) WithOptions(fns ...OptionFn) *Manager {
 	for _, fn := range fns {
 		fn(&em.Options)
 	}
+	em.initLanes()
+
+	// add the listened names to the custom matcher
+	if em.Matcher != nil {
+		em.mu.Lock()
+		for name := range em.listeners {
+			em.Matcher.Add(name)
+		}
+		em.mu.Unlock()
+	}
 	return em
 }
 
@@ -88,28 +132,83 @@ func (em *Manager) Listen(name string, listener Listener, priority ...int) {
 }
 
 // Once register an event handler/listener. trigger once.
+//
+// It is called at most once, even the event is fired concurrently.
 func (em *Manager) Once(name string, listener Listener, priority ...int) {
-	var listenerOnce Listener
-	listenerOnce = ListenerFunc(func(e Event) error {
-		em.RemoveListener(name, listenerOnce)
-		return listener.Handle(e)
-	})
-	em.On(name, listenerOnce, priority...)
+	em.RegisterOnce(name, listener, priority...)
+}
+
+// Times register an event handler/listener, it is called at most n times, then removed.
+//
+// Usage:
+//
+//	em.Times("app.retry", listener, 3)
+func (em *Manager) Times(name string, n int, listener Listener, priority ...int) *Subscription {
+	if n <= 0 {
+		panicf("event: the event %q listener times must be greater than 0", name)
+	}
+
+	li := &ListenerItem{Priority: priorityOf(priority), Listener: listener, limit: int64(n)}
+	em.addListenerItem(name, li)
+	return &Subscription{em: em, item: li}
+}
+
+// Until register an event handler/listener, it is removed after the predicate first returns true.
+//
+// The predicate is checked with the event after the listener called.
+//
+// Usage:
+//
+//	em.Until("job.progress", listener, func(e event.Event) bool {
+//		return e.Get("percent") == 100
+//	})
+func (em *Manager) Until(name string, listener Listener, predicate func(e Event) bool, priority ...int) *Subscription {
+	if predicate == nil {
+		panicf("event: the event %q listener predicate cannot be empty", name)
+	}
+
+	li := &ListenerItem{Priority: priorityOf(priority), Listener: listener, until: predicate}
+	em.addListenerItem(name, li)
+	return &Subscription{em: em, item: li}
 }
 
 // On register a event handler/listener. can setting priority.
 //
+// Listeners with higher priority are called first, listeners
+// with the same priority are called in the registration order.
+//
 // Usage:
 //
 //	em.On("evt0", listener)
 //	em.On("evt0", listener, High)
 func (em *Manager) On(name string, listener Listener, priority ...int) {
-	pv := Normal
+	em.Register(name, listener, priority...)
+}
+
+// Register a event handler/listener like On(), returns the Subscription handle of the registration.
+//
+// Usage:
+//
+//	sub := em.Register("user.created", listener, High)
+//	// remove exactly this registration
+//	sub.Unsubscribe()
+func (em *Manager) Register(name string, listener Listener, priority ...int) *Subscription {
+	li := &ListenerItem{Priority: priorityOf(priority), Listener: listener}
+	em.addListenerItem(name, li)
+	return &Subscription{em: em, item: li}
+}
+
+// RegisterOnce register a event handler/listener like Once(), returns the Subscription handle.
+func (em *Manager) RegisterOnce(name string, listener Listener, priority ...int) *Subscription {
+	return em.Times(name, 1, listener, priority...)
+}
+
+// priorityOf get the priority from the optional args. default is Normal
+func priorityOf(priority []int) int {
 	if len(priority) > 0 {
-		pv = priority[0]
+		return priority[0]
 	}
-
-	em.addListenerItem(name, &ListenerItem{pv, listener})
+	return Normal
 }
 
 // Subscribe add events by subscriber interface. alias of the AddSubscriber()
@@ -137,7 +236,10 @@ func (em *Manager) AddSubscriber(sbr Subscriber) {
 }
 
 func (em *Manager) addListenerItem(name string, li *ListenerItem) {
-	name = goodName(name, true)
+	name, err := em.checkName(name, true)
+	if err != nil {
+		panic(err)
+	}
 	if li.Listener == nil {
 		panicf("event: the event %q listener cannot be empty", name)
 	}
@@ -145,22 +247,41 @@ func (em *Manager) addListenerItem(name string, li *ListenerItem) {
 		panicf("event: %q - struct listener must be pointer", name)
 	}
 
-	// exists, append it.
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	em.initItem(li, name)
+
+	// exists, insert it by priority.
 	if lq, ok := em.listeners[name]; ok {
 		lq.Push(li)
 	} else { // first add.
 		em.listenedNames[name] = 1
 		em.listeners[name] = (&ListenerQueue{}).Push(li)
+
+		em.pathM.Add(name)
+		if em.Matcher != nil {
+			em.Matcher.Add(name)
+		}
 	}
 }
 
+// initItem init the registration of the item, must be called with em.mu locked.
+func (em *Manager) initItem(li *ListenerItem, name string) {
+	em.seq++
+	li.seq = em.seq
+	li.name = name
+	li.state = &itemState{}
+	em.buildHandler(li)
+}
+
 /*************************************************************
  * region Event Manage
  *************************************************************/
 
 // AddEvent add a pre-defined event instance to manager.
 func (em *Manager) AddEvent(e Event) error {
-	name, err := goodNameOrErr(e.Name(), false)
+	name, err := em.checkName(e.Name(), false)
 	if err != nil {
 		return err
 	}
@@ -179,7 +300,7 @@ func (em *Manager) AddEvent(e Event) error {
 
 // AddEventFc add a pre-defined event factory func to manager.
 func (em *Manager) AddEventFc(name string, fc FactoryFunc) (err error) {
-	name, err = goodNameOrErr(name, false)
+	name, err = em.checkName(name, false)
 	if err == nil {
 		em.addEventFc(name, fc)
 	}
@@ -187,14 +308,17 @@ func (em *Manager) AddEventFc(name string, fc FactoryFunc) (err error) {
 }
 
 func (em *Manager) addEventFc(name string, fc FactoryFunc) {
-	em.Lock()
+	em.mu.Lock()
 	em.eventFc[name] = fc
-	em.Unlock()
+	em.mu.Unlock()
 }
 
 // GetEvent get a pre-defined event instance by name
 func (em *Manager) GetEvent(name string) (e Event, ok bool) {
+	em.mu.RLock()
 	fc, ok := em.eventFc[name]
+	em.mu.RUnlock()
+
 	if ok {
 		return fc(), true
 	}
@@ -203,26 +327,46 @@ func (em *Manager) GetEvent(name string) (e Event, ok bool) {
 
 // HasEvent has pre-defined event check
 func (em *Manager) HasEvent(name string) bool {
+	em.mu.RLock()
 	_, ok := em.eventFc[name]
+	em.mu.RUnlock()
 	return ok
 }
 
 // RemoveEvent delete pre-define Event by name
 func (em *Manager) RemoveEvent(name string) {
-	if _, ok := em.eventFc[name]; ok {
-		delete(em.eventFc, name)
-	}
+	em.mu.Lock()
+	delete(em.eventFc, name)
+	em.mu.Unlock()
 }
 
 // RemoveEvents remove all registered events
 func (em *Manager) RemoveEvents() {
+	em.mu.Lock()
 	em.eventFc = map[string]FactoryFunc{}
+	em.mu.Unlock()
 }
 
 /*************************************************************
  * region Helper Methods
  *************************************************************/
 
+// matcher get the event name Matcher. the custom Matcher or by the MatchMode.
+func (em *Manager) matcher() Matcher {
+	if em.Matcher != nil {
+		return em.Matcher
+	}
+	if em.MatchMode == ModePath {
+		return em.pathM
+	}
+	return simpleMatcher{}
+}
+
+// checkName check and normalize the event name by the matcher.
+func (em *Manager) checkName(name string, isReg bool) (string, error) {
+	return em.matcher().CheckName(name, isReg)
+}
+
 // newBasicEvent create new BasicEvent by clone em.sample
 func (em *Manager) newBasicEvent(name string, data M) *BasicEvent {
 	var cp = *em.sample
@@ -233,29 +377,56 @@ func (em *Manager) newBasicEvent(name string, data M) *BasicEvent {
 
 // HasListeners check has direct listeners for the event name.
 func (em *Manager) HasListeners(name string) bool {
+	em.mu.RLock()
 	_, ok := em.listenedNames[name]
+	em.mu.RUnlock()
 	return ok
 }
 
-// Listeners get all listeners
-func (em *Manager) Listeners() map[string]*ListenerQueue { return em.listeners }
+// Listeners get all listeners. returns a copy of the name to ListenerQueue map, the queues are copied too.
+func (em *Manager) Listeners() map[string]*ListenerQueue {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+
+	mp := make(map[string]*ListenerQueue, len(em.listeners))
+	for name, lq := range em.listeners {
+		mp[name] = lq.clone()
+	}
+	return mp
+}
 
-// ListenersByName get listeners by given event name
+// ListenersByName get listeners by given event name. returns a copy of the ListenerQueue.
 func (em *Manager) ListenersByName(name string) *ListenerQueue {
-	return em.listeners[name]
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+
+	if lq, ok := em.listeners[name]; ok {
+		return lq.clone()
+	}
+	return nil
 }
 
 // ListenersCount get listeners number for the event name.
 func (em *Manager) ListenersCount(name string) int {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+
 	if lq, ok := em.listeners[name]; ok {
 		return lq.Len()
 	}
 	return 0
 }
 
-// ListenedNames get listened event names
+// ListenedNames get listened event names. returns a copy of the names map.
 func (em *Manager) ListenedNames() map[string]int {
-	return em.listenedNames
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+
+	mp := make(map[string]int, len(em.listenedNames))
+	for name, n := range em.listenedNames {
+		mp[name] = n
+	}
+	return mp
 }
 
 // RemoveListener remove a given listener, you can limit event name.
@@ -265,14 +436,16 @@ func (em *Manager) ListenedNames() map[string]int {
 //	RemoveListener("", listener)
 //	RemoveListener("name", listener) // limit event name.
 func (em *Manager) RemoveListener(name string, listener Listener) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
 	if name != "" {
 		if lq, ok := em.listeners[name]; ok {
-			lq.Remove(listener)
+			stopItems(lq.removeListener(listener))
 
 			// delete from manager
 			if lq.IsEmpty() {
-				delete(em.listeners, name)
-				delete(em.listenedNames, name)
+				em.deleteListened(name)
 			}
 		}
 		return
@@ -280,25 +453,39 @@ func (em *Manager) RemoveListener(name string, listener Listener) {
 
 	// name is empty. find all listener and remove matched.
 	for name, lq := range em.listeners {
-		lq.Remove(listener)
+		stopItems(lq.removeListener(listener))
 
 		// delete from manager
 		if lq.IsEmpty() {
-			delete(em.listeners, name)
-			delete(em.listenedNames, name)
+			em.deleteListened(name)
 		}
 	}
 }
 
 // RemoveListeners remove listeners by given name
 func (em *Manager) RemoveListeners(name string) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
 	_, ok := em.listenedNames[name]
 	if ok {
-		em.listeners[name].Clear()
-
 		// delete from manager
-		delete(em.listeners, name)
-		delete(em.listenedNames, name)
+		em.deleteListened(name)
+	}
+}
+
+// deleteListened delete the listened name and its listeners from manager. must be called with em.mu locked.
+func (em *Manager) deleteListened(name string) {
+	if lq, ok := em.listeners[name]; ok {
+		stopItems(lq.items)
+		lq.Clear()
+	}
+
+	delete(em.listeners, name)
+	delete(em.listenedNames, name)
+	em.pathM.Remove(name)
+	if em.Matcher != nil {
+		em.Matcher.Remove(name)
 	}
 }
 
@@ -306,26 +493,41 @@ func (em *Manager) RemoveListeners(name string) {
 func (em *Manager) Clear() { em.Reset() }
 
 // Close event channel, deny to fire new event.
+//
+// The queued events will still be consumed, fire new async event will get ErrManagerClosed.
 func (em *Manager) Close() error {
-	if em.ch != nil {
-		close(em.ch)
+	for _, l := range em.allLanes() {
+		l.close()
 	}
 	return nil
 }
 
 // Reset the manager, clear all data.
 func (em *Manager) Reset() {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
 	// clear all listeners
-	for _, lq := range em.listeners {
+	for name, lq := range em.listeners {
+		stopItems(lq.items)
 		lq.Clear()
+		if em.Matcher != nil {
+			em.Matcher.Remove(name)
+		}
 	}
 
-	// reset all
-	em.ch = nil
-	em.oc = sync.Once{}
-	em.wg = sync.WaitGroup{}
+	// reset all. the consumers of old queue will exit after it is drained,
+	// so the wg is kept for them.
+	for _, l := range em.allLanes() {
+		l.reset()
+	}
+	em.clearErrors()
 
 	em.eventFc = make(map[string]FactoryFunc)
 	em.listeners = make(map[string]*ListenerQueue)
 	em.listenedNames = make(map[string]int)
+	// clear in place, the pathM is read without lock by matcher()
+	em.pathM.trie.Clear()
+	em.typeListeners = make(map[reflect.Type]*ListenerQueue)
+	em.mws.Store(nil)
 }
@@ -2,8 +2,8 @@ package event
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/gookit/goutil/x/basefn"
 )
@@ -49,6 +49,13 @@ func (em *Manager) Async(name string, params M) {
 	_, _ = em.fireByName(name, params, true)
 }
 
+// AsyncCtx async fire event by go channel, and with context.
+//
+// The event will be dropped if the context is canceled before it is consumed.
+func (em *Manager) AsyncCtx(ctx context.Context, name string, params M) {
+	_, _ = em.fireByNameCtx(ctx, name, params, true)
+}
+
 // FireC async fire event by go channel. alias of the method Async()
 //
 // Note: if you want to use this method, you should
@@ -70,14 +77,14 @@ func (em *Manager) fireByName(name string, params M, useCh bool) (Event, error)
 
 // fireByNameCtx fire event by name with context
 func (em *Manager) fireByNameCtx(ctx context.Context, name string, params M, useCh bool) (e Event, err error) {
-	name, err = goodNameOrErr(name, false)
+	name, err = em.checkName(name, false)
 	if err != nil {
 		return nil, err
 	}
 
 	// use pre-defined Event
-	if fc, ok := em.eventFc[name]; ok {
-		e = fc() // make new instance
+	if pe, ok := em.GetEvent(name); ok {
+		e = pe // new instance made by the factory func
 		if params != nil {
 			e.SetData(params)
 		}
@@ -88,11 +95,7 @@ func (em *Manager) fireByNameCtx(ctx context.Context, name string, params M, use
 
 	// warp context
 	if ctx != nil {
-		if ec, ok := e.(ContextAble); ok {
-			ec.WithContext(ctx)
-		} else {
-			e = newContextEvent(ctx, e)
-		}
+		e = withContext(ctx, e)
 	}
 
 	// fire by channel
@@ -111,156 +114,166 @@ func (em *Manager) FireEvent(e Event) error { return em.fireEvent(e) }
 
 // FireEventCtx fire event by given Event instance with context
 func (em *Manager) FireEventCtx(ctx context.Context, e Event) (err error) {
-	if ec, ok := e.(ContextAble); ok {
-		ec.WithContext(ctx)
-		return em.fireEvent(ec)
-	}
-	return em.fireEvent(newContextEvent(ctx, e))
+	return em.fireEvent(withContext(ctx, e))
 }
 
-// FireEventCtx fire event by given Event instance with context
-func (em *Manager) fireEvent(e Event) (err error) {
-	if em.EnableLock {
-		em.Lock()
-		defer em.Unlock()
+// fireEvent call matched listeners to handle the event.
+//
+// Listeners are read from copy-on-write snapshots, no lock is held
+// while calling them, so a listener can fire other events on the manager.
+func (em *Manager) fireEvent(e Event) error {
+	return em.putRetried(e, em.fireEventBy(e, em.PanicPolicy))
+}
+
+// putRetried put the event failed after retries to the dead-letter sink, returns the err.
+func (em *Manager) putRetried(e Event, err error) error {
+	var re *RetryError
+	if err != nil && errors.As(err, &re) {
+		em.putDeadLetter(e, err)
 	}
+	return err
+}
 
+// fireEventBy call matched listeners to handle the event, with the panic policy.
+func (em *Manager) fireEventBy(e Event, policy uint8) error {
 	// ensure aborted is false.
 	e.Abort(false)
-	name := e.Name()
 
+	m := em.matcher()
+	// for set named params captured by the pattern of the listener
+	pm, _ := m.(ParamsMatcher)
+	return em.fireItems(e, em.matchedItems(m, e.Name()), pm, policy)
+}
+
+// fireItems sweep the expired listeners and call the listener items to handle the event.
+func (em *Manager) fireItems(e Event, items []*ListenerItem, pm ParamsMatcher, policy uint8) error {
+	em.lazySweep()
+	return em.callItems(e, items, pm, policy)
+}
+
+// callItems call the listener items to handle the event, stop on error or aborted.
+//
+// On PanicContinue, the recovered panics are collected and joined with the final error.
+func (em *Manager) callItems(e Event, items []*ListenerItem, pm ParamsMatcher, policy uint8) (err error) {
 	// get context
 	var ctx context.Context
 	if ec, ok := e.(ContextAble); ok {
 		ctx = ec.Context()
 	}
 
-	// fire group listeners by wildcard. eg "db.user.*"
-	if em.MatchMode == ModePath {
-		err = em.firePathMode(ctx, name, e)
-		return
-	}
-
-	// handle mode: ModeSimple
-	err = em.fireSimpleMode(ctx, name, e)
-	if err != nil || e.IsAborted() {
-		return
-	}
-
-	// fire wildcard event listeners
-	if lq, ok := em.listeners[Wildcard]; ok {
-		for _, li := range lq.Sort().Items() {
-			// Check context cancellation
-			if ctx != nil {
-				select {
-				case <-ctx.Done():
-					err = ctx.Err()
-					return
-				default:
-				}
-			}
+	name := e.Name()
+	pe, _ := e.(ParamsAble)
 
-			err = li.Listener.Handle(e)
-			if err != nil || e.IsAborted() {
+	var panics []error
+	for _, li := range items {
+		// Check context cancellation
+		if ctx != nil {
+			if err = ctx.Err(); err != nil {
 				break
 			}
 		}
-	}
-	return
-}
 
-// ModeSimple has group listeners by wildcard. eg "db.user.*"
-//
-// Example:
-//   - event "db.user.add" will trigger listeners on the "db.user.*"
-func (em *Manager) fireSimpleMode(ctx context.Context, name string, e Event) (err error) {
-	// fire direct matched listeners. eg: db.user.add
-	if lq, ok := em.listeners[name]; ok {
-		// sort by priority before call.
-		for _, li := range lq.Sort().Items() {
-			// Check context cancellation
-			if ctx != nil {
-				select {
-				case <-ctx.Done():
-					err = ctx.Err()
-					return
-				default:
-				}
-			}
+		// the ctx of listener is done, remove it.
+		if li.expired() {
+			em.removeItem(li)
+			continue
+		}
 
-			err = li.Listener.Handle(e)
-			if err != nil || e.IsAborted() {
-				return
+		// check the active window of listener
+		if li.hasWindow() {
+			now := em.now()
+			if li.expiredAt(now) {
+				em.removeItem(li)
+				continue
 			}
+			if li.inactiveAt(now) {
+				continue
+			}
+		}
+
+		// check the call limit, remove it before call on the last time.
+		ok, last := li.acquire()
+		if !ok {
+			continue
+		}
+		if last {
+			em.removeItem(li)
+		}
+
+		if pm != nil && pe != nil {
+			pe.SetParams(pm.Params(li.name, name))
+		}
+
+		var panicked bool
+		panicked, err = em.callListener(e, li, policy != PanicPropagate)
+		if li.until != nil && !panicked && li.until(e) && li.finish() {
+			em.removeItem(li)
+		}
+		if panicked && policy == PanicContinue {
+			panics = append(panics, err)
+			err = nil
+		}
+
+		if err != nil || e.IsAborted() {
+			break
 		}
 	}
 
-	pos := strings.LastIndexByte(name, '.')
-
-	// exists group
-	if pos > 0 && pos < len(name) {
-		groupName := name[:pos+1] + Wildcard // "app.*"
-
-		if lq, ok := em.listeners[groupName]; ok {
-			for _, li := range lq.Sort().Items() {
-				// Check context cancellation
-				if ctx != nil {
-					select {
-					case <-ctx.Done():
-						err = ctx.Err()
-						return
-					default:
-					}
-				}
-
-				err = li.Listener.Handle(e)
-				if err != nil || e.IsAborted() {
-					return
-				}
+	if len(panics) > 0 {
+		return joinErrors(append(panics, err)...)
+	}
+	return err
+}
+
+// callListener call the listener to handle the event, wrapped by the middlewares and Options.RetryPolicy
+// if recovered is true, will recover the panic and returns it as *ListenerPanicError.
+func (em *Manager) callListener(e Event, li *ListenerItem, recovered bool) (panicked bool, err error) {
+	if recovered {
+		defer func() {
+			if r := recover(); r != nil {
+				panicked, err = true, newListenerPanicError(e.Name(), li.Listener, r)
 			}
-		}
+		}()
 	}
 
-	return nil
+	return false, em.handlerOf(li).Handle(e)
 }
 
-// firePathMode fire group listeners by ModePath.
+// matchedItems find the listeners of all patterns matched the event name.
 //
-// Example:
-//   - event "db.user.add" will trigger listeners on the "db.**"
-//   - event "db.user.add" will trigger listeners on the "db.user.*"
-func (em *Manager) firePathMode(ctx context.Context, name string, e Event) (err error) {
-	for pattern, lq := range em.listeners {
-		if pattern == name || matchNodePath(pattern, name, ".") {
-			for _, li := range lq.Sort().Items() {
-				// Check context cancellation
-				if ctx != nil {
-					select {
-					case <-ctx.Done():
-						err = ctx.Err()
-						return
-					default:
-					}
-				}
-
-				err = li.Listener.Handle(e)
-				if err != nil || e.IsAborted() {
-					return
-				}
-			}
+// On ModeSimple, listeners are called group by group. eg "db.user.add":
+//   - direct listeners on the "db.user.add"
+//   - group listeners on the "db.user.*"
+//   - wildcard listeners on the "*"
+//
+// On other modes, listeners of all matched patterns are merged and called in one
+// sequence by priority, listeners with same priority by the registration order.
+// eg "db.user.add" will trigger listeners on the "db.**", "db.user.*" on ModePath
+func (em *Manager) matchedItems(m Matcher, name string) []*ListenerItem {
+	// collect the snapshots under the read lock, call them without holding it.
+	var groups [][]*ListenerItem
+	em.mu.RLock()
+	for _, pattern := range m.Match(name) {
+		if lq, ok := em.listeners[pattern]; ok {
+			groups = append(groups, lq.items)
 		}
 	}
+	em.mu.RUnlock()
 
-	return nil
+	if _, ok := m.(simpleMatcher); ok && len(groups) > 1 {
+		var items []*ListenerItem
+		for _, g := range groups {
+			items = append(items, g...)
+		}
+		return items
+	}
+	return mergeItems(groups)
 }
 
 /*************************************************************
  * region Fire by channel
  *************************************************************/
 
-// FireAsyncCtx async fire event by go channel, and with context TODO need?
-// func (em *Manager) FireAsyncCtx(ctx context.Context, e Event)
-
 // FireAsync async fire event by go channel.
 //
 // Note: if you want to use this method, you should
@@ -270,47 +283,224 @@ func (em *Manager) firePathMode(ctx context.Context, name string, e Event) (err
 //
 //	em := NewManager("test")
 //	em.FireAsync("db.user.add", M{"id": 1001})
+//
+// On the queue is full, it will be handled by the Options.Overflow policy.
+// the errors(eg: ErrQueueFull, ErrManagerClosed) will be reported by Options.AsyncErrorHandler
 func (em *Manager) FireAsync(e Event) {
-	// once make consumers
-	em.oc.Do(func() {
-		em.makeConsumers()
-	})
+	em.FireAsyncKey("", e)
+}
+
+// FireAsyncKey async fire event by go channel, with a partition key.
+//
+// Events with the same partition key are handled sequentially by the same consumer,
+// events with different keys are still handled in parallel.
+// if the key is empty, will use the PartitionKey() if the event implements Partitioned.
+//
+// Example:
+//
+//	em.FireAsyncKey("order-1001", event.New("order.created", nil))
+//	em.FireAsyncKey("order-1001", event.New("order.paid", nil))
+func (em *Manager) FireAsyncKey(key string, e Event) {
+	em.fireAsync(key, eventPriority(e), e)
+}
+
+// FireAsyncPriority async fire event by go channel, with the event priority.
+//
+// Higher priority events are consumed first on the priority queue, see Options.PriorityQueue
+// On the FIFO queue, the priority is ignored.
+//
+// Example:
+//
+//	em.FireAsyncPriority(event.High, event.New("payment.failed", nil))
+func (em *Manager) FireAsyncPriority(priority int, e Event) {
+	em.fireAsync("", priority, e)
+}
+
+func (em *Manager) fireAsync(key string, priority int, e Event) {
+	l := em.laneOf(e.Name())
+	err := em.pushAsync(l, key, priority, e, false)
+	// drop newest silently, it is counted by DroppedCount()
+	if err != nil && !(err == ErrQueueFull && *l.overflow == OverflowDropNewest) {
+		em.reportAsyncError(e, err)
+	}
+}
+
+// TryFireAsync async fire event by go channel, never block the caller.
+//
+// Returns ErrQueueFull if the queue is full, ErrManagerClosed if the manager is closed.
+// On OverflowDropOldest, will drop the oldest event in the queue instead.
+func (em *Manager) TryFireAsync(e Event) error {
+	return em.pushAsync(em.laneOf(e.Name()), "", eventPriority(e), e, true)
+}
 
-	// dispatch event
-	em.ch <- e
+// pushAsync push the event to the queue of the lane.
+func (em *Manager) pushAsync(l *asyncLane, key string, priority int, e Event, try bool) error {
+	if key == "" {
+		if pe, ok := e.(Partitioned); ok {
+			key = pe.PartitionKey()
+		}
+	}
+	return l.push(em, key, priority, e, try)
 }
 
-// async fire event by 'go' keywords
-func (em *Manager) makeConsumers() {
-	if em.ConsumerNum <= 0 {
-		em.ConsumerNum = defaultConsumerNum
+// DroppedCount get the number of the async events dropped by the overflow policy, of all lanes.
+func (em *Manager) DroppedCount() uint64 {
+	var n uint64
+	for _, l := range em.allLanes() {
+		n += l.dropped.Load()
 	}
-	if em.ChannelSize <= 0 {
-		em.ChannelSize = defaultChannelSize
+	return n
+}
+
+// LaneDroppedCount get the number of the async events dropped by the lane. see DefaultLane
+func (em *Manager) LaneDroppedCount(lane string) uint64 {
+	if l := em.findLane(lane); l != nil {
+		return l.dropped.Load()
 	}
+	return 0
+}
 
-	em.ch = make(chan Event, em.ChannelSize)
+// FireAsyncCtx async fire event by go channel, and with context.
+//
+// The context is kept by the event(see ContextAble), the consumer will:
+//   - drop the event if the context is canceled while it is waiting in the queue.
+//   - stop calling the rest listeners if the context is canceled between listeners.
+//
+// Example:
+//
+//	em.FireAsyncCtx(ctx, event.New("db.user.add", event.M{"id": 1001}))
+func (em *Manager) FireAsyncCtx(ctx context.Context, e Event) {
+	em.FireAsync(withContext(ctx, e))
+}
 
-	// make event consumers
-	for i := 0; i < em.ConsumerNum; i++ {
-		em.wg.Add(1)
+// consume the events from the shared and the keyed channel, until both closed.
+func (em *Manager) consume(q *eventQueue, keyed chan Event) {
+	shared := q.ch
+	// on the priority queue, ask for the next event by ready, then receive it from shared.
+	var ready chan struct{}
+	if q.pq != nil {
+		ready = q.pq.ready
+	}
 
-		go func() {
-			defer func() {
-				if err := recover(); err != nil {
-					em.err = fmt.Errorf("async consum event error: %v", err)
-				}
-				em.wg.Done()
-			}()
+	for shared != nil || keyed != nil {
+		var e Event
+		var ok bool
 
-			// keep running until channel closed
-			for e := range em.ch {
-				_ = em.FireEvent(e) // ignore async fire error
+		select {
+		case e, ok = <-shared:
+			if !ok {
+				shared, ready = nil, nil
+				continue
 			}
-		}()
+		case ready <- struct{}{}:
+			if e, ok = <-shared; !ok {
+				shared, ready = nil, nil
+				continue
+			}
+		case e, ok = <-keyed:
+			if !ok {
+				keyed = nil
+				continue
+			}
+		}
+
+		// the queue is stopped by Shutdown(), discard the rest events.
+		if !q.take() {
+			continue
+		}
+		// context canceled while waiting in the queue, drop it.
+		if isCanceled(e) {
+			continue
+		}
+		em.consumeEvent(e)
 	}
 }
 
+// consumeEvent handle an event from the channel, report the error or panic.
+//
+// The listener panics are always recovered, keep the consumer goroutine alive.
+func (em *Manager) consumeEvent(e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			em.reportAsyncError(e, fmt.Errorf("event: async consume event panic: %v", r))
+		}
+	}()
+
+	policy := em.PanicPolicy
+	if policy == PanicPropagate {
+		policy = PanicStop
+	}
+
+	// error by the context canceled between listeners is not reported.
+	if err := em.fireEventBy(e, policy); err != nil && !isCanceled(e) {
+		em.reportAsyncError(e, err)
+	}
+}
+
+// reportAsyncError collect the async error, call the AsyncErrorHandler and put the event to the dead-letter sink.
+func (em *Manager) reportAsyncError(e Event, err error) {
+	ae := &AsyncError{Name: e.Name(), Event: e, Err: err}
+
+	// keep limited errors for Wait(), the rest are only reported to the AsyncErrorHandler.
+	em.errMu.Lock()
+	if len(em.errs) < maxAsyncErrors {
+		em.errs = append(em.errs, ae)
+	} else {
+		em.errOmitted++
+	}
+	em.errMu.Unlock()
+
+	if em.AsyncErrorHandler != nil {
+		em.AsyncErrorHandler(e, ae)
+	}
+	em.putDeadLetter(e, err)
+}
+
+/*************************************************************
+ * region Async consumers
+ *************************************************************/
+
+// SetConsumerNum set the number of async consumers of the default lane at runtime.
+//
+// If the consumers are running, will switch to a new queue with n consumers,
+// the queued events of the old queue are still consumed before the new consumers start.
+func (em *Manager) SetConsumerNum(n int) {
+	em.lane.setConsumerNum(em, n)
+}
+
+// SetLaneConsumerNum set the number of async consumers of the lane at runtime. see SetConsumerNum()
+func (em *Manager) SetLaneConsumerNum(lane string, n int) {
+	if l := em.findLane(lane); l != nil {
+		l.setConsumerNum(em, n)
+	}
+}
+
+// Restart the async consumers with a new queue, the registered listeners are kept.
+//
+// It can re-open the manager after Close() or Shutdown(). the collected async errors will be cleared.
+// If the consumers are running, the queued events of the old queue are still consumed.
+func (em *Manager) Restart() {
+	em.clearErrors()
+
+	for _, l := range em.allLanes() {
+		l.restart(em)
+	}
+}
+
+// Shutdown close the queue and wait for the queued events consumed, until the context done.
+//
+// On the context done, the rest queued events will be discarded,
+// returns the number of them and the context error.
+//
+// Usage:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	n, err := em.Shutdown(ctx)
+func (em *Manager) Shutdown(ctx context.Context) (undelivered int, err error) {
+	return em.shutdownLanes(ctx)
+}
+
 // FireBatch fire multi event at once.
 //
 // Usage:
@@ -369,7 +559,56 @@ func (em *Manager) CloseWait() error {
 }
 
 // Wait wait all async event done.
+//
+// Returns the errors on consume the events, joined into one error.
+// each of them is an *AsyncError. the errors are cleared after returned.
+//
+// NOTE: at most 100 errors are kept between two Wait() calls, the rest are counted
+// by a last error "N more async errors omitted".
+// use the Options.AsyncErrorHandler to receive all of them.
 func (em *Manager) Wait() error {
 	em.wg.Wait()
-	return em.err
+
+	em.errMu.Lock()
+	defer em.errMu.Unlock()
+
+	errs := em.errs
+	if em.errOmitted > 0 {
+		errs = append(errs, fmt.Errorf("event: %d more async errors omitted", em.errOmitted))
+	}
+
+	em.errs, em.errOmitted = nil, 0
+	return joinErrors(errs...)
+}
+
+// clearErrors clear the collected async errors
+func (em *Manager) clearErrors() {
+	em.errMu.Lock()
+	em.errs, em.errOmitted = nil, 0
+	em.errMu.Unlock()
+}
+
+// withContext set the context to the event. will wrap it if it is not ContextAble.
+func withContext(ctx context.Context, e Event) Event {
+	if ec, ok := e.(ContextAble); ok {
+		ec.WithContext(ctx)
+		return ec
+	}
+	return newContextEvent(ctx, e)
+}
+
+// isCanceled check the context of the event is canceled.
+func isCanceled(e Event) bool {
+	if ec, ok := e.(ContextAble); ok {
+		return ec.Context().Err() != nil
+	}
+	return false
+}
+
+// eventPriority get the priority of the event for async fire. default is Normal
+func eventPriority(e Event) int {
+	if pe, ok := e.(Prioritized); ok {
+		return pe.Priority()
+	}
+	return Normal
 }
@@ -2,9 +2,12 @@ package event_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -383,6 +386,1185 @@ func TestManager_Wait(t *testing.T) {
 	buf.Reset()
 }
 
+func TestManager_FireAsyncCtx(t *testing.T) {
+	em := event.NewManager("test", event.WithConsumerNum(1))
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	var handled []string
+	em.On("evt.block", event.ListenerFunc(func(e event.Event) error {
+		close(started)
+		<-block
+		return nil
+	}))
+	em.On("evt.*", event.ListenerFunc(func(e event.Event) error {
+		var val string
+		if ec, ok := e.(event.ContextAble); ok {
+			val, _ = ec.Context().Value(ctxKey("trace_id")).(string)
+		}
+		handled = append(handled, e.Name()+":"+val)
+		return nil
+	}))
+
+	em.Async("evt.block", nil)
+	<-started
+
+	// canceled while waiting in the queue, will be dropped
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	em.AsyncCtx(ctx1, "evt.drop", nil)
+	cancel1()
+
+	ctx2 := context.WithValue(context.Background(), ctxKey("trace_id"), "t2")
+	em.FireAsyncCtx(ctx2, event.New("evt.keep", nil))
+
+	close(block)
+	assert.NoErr(t, em.CloseWait())
+	assert.Eq(t, []string{"evt.block:", "evt.keep:t2"}, handled)
+}
+
+func TestManager_FireAsyncCtx_cancelBetweenListeners(t *testing.T) {
+	em := event.NewManager("test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls []string
+	em.On("evt1", event.ListenerFunc(func(e event.Event) error {
+		calls = append(calls, "first")
+		cancel()
+		return nil
+	}), event.High)
+	em.On("evt1", event.ListenerFunc(func(e event.Event) error {
+		calls = append(calls, "second")
+		return nil
+	}))
+
+	em.FireAsyncCtx(ctx, event.New("evt1", nil))
+	assert.NoErr(t, em.CloseWait())
+	assert.Eq(t, []string{"first"}, calls)
+}
+
+func TestManager_OnAsyncError(t *testing.T) {
+	var mu sync.Mutex
+	var reported []string
+	em := event.NewManager("test", event.OnAsyncError(func(e event.Event, err error) {
+		mu.Lock()
+		reported = append(reported, err.Error())
+		mu.Unlock()
+	}))
+
+	em.On("evt.err", event.ListenerFunc(func(e event.Event) error {
+		return fmt.Errorf("error on %s", e.Get("id"))
+	}))
+	em.On("evt.panic", event.ListenerFunc(func(e event.Event) error {
+		panic("listener panic")
+	}))
+	em.On("evt.ok", event.ListenerFunc(emptyListener))
+
+	em.Async("evt.err", event.M{"id": "1"})
+	em.FireC("evt.panic", nil)
+	em.FireAsync(event.New("evt.err", event.M{"id": "2"}))
+	em.Async("evt.ok", nil)
+
+	err := em.CloseWait()
+	assert.Err(t, err)
+	assert.Len(t, reported, 3)
+	assert.StrContains(t, err.Error(), `event: async handle event "evt.err" error: error on 1`)
+	assert.StrContains(t, err.Error(), `event: async handle event "evt.err" error: error on 2`)
+	assert.StrContains(t, err.Error(), `event: async handle event "evt.panic" error: event: listener `)
+	assert.StrContains(t, err.Error(), `panic on event "evt.panic": listener panic`)
+
+	// each joined error is an *AsyncError
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	assert.Len(t, errs, 3)
+	for _, e := range errs {
+		ae, ok := e.(*event.AsyncError)
+		assert.True(t, ok)
+		assert.NotEmpty(t, ae.Name)
+		assert.NotNil(t, ae.Event)
+	}
+
+	// cleared after returned
+	assert.NoErr(t, em.Wait())
+}
+
+func TestManager_Wait_limitErrors(t *testing.T) {
+	var reported int32
+	em := event.NewManager("test", event.OnAsyncError(func(e event.Event, err error) {
+		atomic.AddInt32(&reported, 1)
+	}))
+	em.On("evt.err", event.ListenerFunc(func(e event.Event) error {
+		return errors.New("listener error")
+	}))
+
+	for i := 0; i < 150; i++ {
+		em.Async("evt.err", nil)
+	}
+
+	err := em.CloseWait()
+	assert.Eq(t, int32(150), atomic.LoadInt32(&reported))
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	assert.Len(t, errs, 101)
+	assert.Eq(t, "event: 50 more async errors omitted", errs[100].Error())
+	assert.NoErr(t, em.Wait())
+}
+
+func TestManager_PanicPolicy(t *testing.T) {
+	var calls []string
+	addListeners := func(em *event.Manager) {
+		em.On("evt1", event.ListenerFunc(func(e event.Event) error {
+			calls = append(calls, "first")
+			panic("first panic")
+		}), event.High)
+		em.On("evt1", event.ListenerFunc(func(e event.Event) error {
+			calls = append(calls, "second")
+			return nil
+		}))
+	}
+
+	// default: propagate
+	em := event.NewManager("test")
+	addListeners(em)
+	assert.Panics(t, func() {
+		_, _ = em.Fire("evt1", nil)
+	})
+
+	// stop
+	calls = calls[:0]
+	em = event.NewManager("test", event.WithPanicPolicy(event.PanicStop))
+	addListeners(em)
+	err, _ := em.Fire("evt1", nil)
+	assert.Eq(t, []string{"first"}, calls)
+
+	pe, ok := err.(*event.ListenerPanicError)
+	assert.True(t, ok)
+	assert.Eq(t, "evt1", pe.Name)
+	assert.Eq(t, "first panic", pe.Value)
+	assert.StrContains(t, pe.ListenerName, "TestManager_PanicPolicy")
+	assert.NotEmpty(t, pe.Stack)
+	assert.NotNil(t, pe.Listener)
+	assert.Nil(t, pe.Unwrap())
+
+	// continue
+	calls = calls[:0]
+	em = event.NewManager("test", event.WithPanicPolicy(event.PanicContinue))
+	addListeners(em)
+	em.On("evt1", event.ListenerFunc(func(e event.Event) error {
+		return errors.New("last error")
+	}), event.Low)
+
+	err, _ = em.Fire("evt1", nil)
+	assert.Eq(t, []string{"first", "second"}, calls)
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	assert.Len(t, errs, 2)
+	_, ok = errs[0].(*event.ListenerPanicError)
+	assert.True(t, ok)
+	assert.Eq(t, "last error", errs[1].Error())
+}
+
+func TestManager_PanicPolicy_asyncWorkerAlive(t *testing.T) {
+	em := event.NewManager("test", event.WithConsumerNum(1))
+
+	var handled []int
+	em.On("evt1", event.ListenerFunc(func(e event.Event) error {
+		if e.Get("n") == 1 {
+			panic(errors.New("panic error"))
+		}
+		handled = append(handled, e.Get("n").(int))
+		return nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		em.Async("evt1", event.M{"n": i})
+	}
+
+	err := em.CloseWait()
+	assert.Eq(t, []int{0, 2}, handled)
+
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	assert.Len(t, errs, 1)
+
+	var pe *event.ListenerPanicError
+	assert.True(t, errors.As(errs[0], &pe))
+	assert.Eq(t, "panic error", pe.Unwrap().Error())
+}
+
+// newBlockedManager create a manager with one consumer and queue size 1,
+// the consumer is blocked on handle the first event until release() called.
+func newBlockedManager(fns ...event.OptionFn) (em *event.Manager, handled func() []int, release func()) {
+	fns = append(fns, event.WithConsumerNum(1), event.WithChannelSize(1))
+	em = event.NewManager("test", fns...)
+
+	var mu sync.Mutex
+	var ns []int
+	block := make(chan struct{})
+	started := make(chan struct{})
+	em.On("evt", event.ListenerFunc(func(e event.Event) error {
+		n := e.Get("n").(int)
+		if n == 1 {
+			close(started)
+			<-block
+		}
+		mu.Lock()
+		ns = append(ns, n)
+		mu.Unlock()
+		return nil
+	}))
+
+	em.Async("evt", event.M{"n": 1})
+	<-started
+	// fill the queue
+	em.Async("evt", event.M{"n": 2})
+
+	handled = func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return ns
+	}
+	return em, handled, func() { close(block) }
+}
+
+func TestManager_Overflow(t *testing.T) {
+	t.Run("drop newest", func(t *testing.T) {
+		em, handled, release := newBlockedManager(event.WithOverflow(event.OverflowDropNewest))
+		em.Async("evt", event.M{"n": 3})
+		assert.Eq(t, uint64(1), em.DroppedCount())
+
+		release()
+		assert.NoErr(t, em.CloseWait())
+		assert.Eq(t, []int{1, 2}, handled())
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		em, handled, release := newBlockedManager(event.WithOverflow(event.OverflowDropOldest))
+		em.Async("evt", event.M{"n": 3})
+		assert.NoErr(t, em.TryFireAsync(event.New("evt", event.M{"n": 4})))
+		assert.Eq(t, uint64(2), em.DroppedCount())
+
+		release()
+		assert.NoErr(t, em.CloseWait())
+		assert.Eq(t, []int{1, 4}, handled())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		em, handled, release := newBlockedManager(event.WithOverflow(event.OverflowError))
+		em.Async("evt", event.M{"n": 3})
+		assert.ErrIs(t, em.TryFireAsync(event.New("evt", event.M{"n": 4})), event.ErrQueueFull)
+		assert.Eq(t, uint64(2), em.DroppedCount())
+
+		release()
+		err := em.CloseWait()
+		assert.Eq(t, []int{1, 2}, handled())
+
+		errs := err.(interface{ Unwrap() []error }).Unwrap()
+		assert.Len(t, errs, 1)
+		assert.ErrIs(t, errs[0], event.ErrQueueFull)
+	})
+
+	t.Run("block timeout", func(t *testing.T) {
+		em, handled, release := newBlockedManager(event.WithBlockTimeout(10 * time.Millisecond))
+		em.Async("evt", event.M{"n": 3})
+		assert.Eq(t, uint64(1), em.DroppedCount())
+
+		release()
+		err := em.CloseWait()
+		assert.Eq(t, []int{1, 2}, handled())
+
+		errs := err.(interface{ Unwrap() []error }).Unwrap()
+		assert.Len(t, errs, 1)
+		assert.ErrIs(t, errs[0], event.ErrQueueFull)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		em, handled, release := newBlockedManager()
+		assert.ErrIs(t, em.TryFireAsync(event.New("evt", event.M{"n": 3})), event.ErrQueueFull)
+
+		done := make(chan struct{})
+		go func() {
+			em.Async("evt", event.M{"n": 4})
+			close(done)
+		}()
+
+		release()
+		<-done
+		assert.NoErr(t, em.CloseWait())
+		assert.Eq(t, []int{1, 2, 4}, handled())
+	})
+}
+
+func TestManager_FireAsync_afterClose(t *testing.T) {
+	em := event.NewManager("test")
+	em.On("evt", event.ListenerFunc(emptyListener))
+	assert.NoErr(t, em.Close())
+
+	assert.NotPanics(t, func() {
+		em.Async("evt", nil)
+	})
+	assert.ErrIs(t, em.TryFireAsync(event.New("evt", nil)), event.ErrManagerClosed)
+	assert.NoErr(t, em.Close())
+
+	err := em.Wait()
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	assert.Len(t, errs, 1)
+	assert.ErrIs(t, errs[0], event.ErrManagerClosed)
+}
+
+type keyedEvent struct {
+	event.BasicEvent
+	key string
+}
+
+func (e *keyedEvent) PartitionKey() string { return e.key }
+
+func TestManager_FireAsyncKey(t *testing.T) {
+	em := event.NewManager("test", event.WithConsumerNum(4))
+
+	var mu sync.Mutex
+	seqs := make(map[string][]int)
+	inflight := make(map[string]int)
+	var overlapped bool
+	em.On("order.*", event.ListenerFunc(func(e event.Event) error {
+		key := e.Get("key").(string)
+		mu.Lock()
+		inflight[key]++
+		if inflight[key] > 1 {
+			overlapped = true
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Microsecond * 50)
+
+		mu.Lock()
+		inflight[key]--
+		seqs[key] = append(seqs[key], e.Get("n").(int))
+		mu.Unlock()
+		return nil
+	}))
+
+	keys := []string{"order-1", "order-2", "order-3"}
+	for i := 0; i < 30; i++ {
+		for j, key := range keys {
+			data := event.M{"key": key, "n": i}
+			if j == 0 {
+				// use the PartitionKey() of the event
+				e := &keyedEvent{key: key}
+				e.SetName("order.paid")
+				e.SetData(data)
+				em.FireAsync(e)
+			} else {
+				em.FireAsyncKey(key, event.New("order.paid", data))
+			}
+		}
+	}
+
+	assert.NoErr(t, em.CloseWait())
+	assert.False(t, overlapped)
+	for _, key := range keys {
+		ns := seqs[key]
+		assert.Len(t, ns, 30)
+		for i, n := range ns {
+			assert.Eq(t, i, n)
+		}
+	}
+}
+
+func TestManager_Restart(t *testing.T) {
+	em := event.NewManager("test")
+
+	var calls atomic.Int64
+	em.On("evt", event.ListenerFunc(func(e event.Event) error {
+		calls.Add(1)
+		return nil
+	}))
+
+	em.Async("evt", nil)
+	assert.NoErr(t, em.CloseWait())
+	em.Async("evt", nil)
+	assert.ErrIs(t, em.Wait().(interface{ Unwrap() []error }).Unwrap()[0], event.ErrManagerClosed)
+
+	// re-open, keep the listeners
+	em.Restart()
+	assert.True(t, em.HasListeners("evt"))
+	em.Async("evt", nil)
+	assert.NoErr(t, em.TryFireAsync(event.New("evt", nil)))
+	assert.NoErr(t, em.CloseWait())
+	assert.Eq(t, int64(3), calls.Load())
+}
+
+func TestManager_SetConsumerNum(t *testing.T) {
+	em := event.NewManager("test", event.WithConsumerNum(1))
+
+	var mu sync.Mutex
+	var ns []int
+	em.On("order.paid", event.ListenerFunc(func(e event.Event) error {
+		mu.Lock()
+		ns = append(ns, e.Get("n").(int))
+		mu.Unlock()
+		return nil
+	}))
+
+	for i := 0; i < 50; i++ {
+		if i == 25 {
+			em.SetConsumerNum(4)
+		}
+		em.FireAsyncKey("order-1", event.New("order.paid", event.M{"n": i}))
+	}
+
+	// 4 consumers handle events in parallel
+	var wg sync.WaitGroup
+	wg.Add(4)
+	em.On("barrier", event.ListenerFunc(func(e event.Event) error {
+		wg.Done()
+		wg.Wait()
+		return nil
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for i := 0; i < 4; i++ {
+		em.Async("barrier", nil)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the consumers are not resized")
+	}
+
+	assert.NoErr(t, em.CloseWait())
+	assert.Eq(t, 4, em.ConsumerNum)
+	assert.Len(t, ns, 50)
+	for i, n := range ns {
+		assert.Eq(t, i, n)
+	}
+}
+
+func TestManager_SetConsumerNum_blockedProducer(t *testing.T) {
+	for name, resize := range map[string]func(em *event.Manager){
+		"set consumer num": func(em *event.Manager) { em.SetConsumerNum(2) },
+		"restart":          func(em *event.Manager) { em.Restart() },
+	} {
+		t.Run(name, func(t *testing.T) {
+			em, handled, release := newBlockedManager()
+
+			// blocked on the full queue
+			pushed := make(chan struct{})
+			go func() {
+				em.Async("evt", event.M{"n": 3})
+				close(pushed)
+			}()
+			time.Sleep(10 * time.Millisecond)
+
+			resize(em)
+			<-pushed
+
+			release()
+			assert.NoErr(t, em.CloseWait())
+			assert.Eq(t, []int{1, 2, 3}, handled())
+		})
+	}
+}
+
+func TestManager_Shutdown(t *testing.T) {
+	// not started
+	n, err := event.NewManager("test").Shutdown(context.Background())
+	assert.NoErr(t, err)
+	assert.Eq(t, 0, n)
+
+	// drained
+	em := event.NewManager("test")
+	em.On("evt", event.ListenerFunc(emptyListener))
+	em.Async("evt", nil)
+	n, err = em.Shutdown(context.Background())
+	assert.NoErr(t, err)
+	assert.Eq(t, 0, n)
+
+	// deadline
+	em, handled, release := newBlockedManager()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	n, err = em.Shutdown(ctx)
+	assert.ErrIs(t, err, context.DeadlineExceeded)
+	assert.Eq(t, 1, n)
+	assert.ErrIs(t, em.TryFireAsync(event.New("evt", event.M{"n": 3})), event.ErrManagerClosed)
+
+	release()
+	assert.Nil(t, em.Wait())
+	assert.Eq(t, []int{1}, handled())
+}
+
+func TestManager_Lanes(t *testing.T) {
+	em := event.NewManager("test",
+		event.WithLane(event.Lane{
+			Name:        "metrics",
+			Patterns:    []string{"metrics.**"},
+			ChannelSize: 1,
+			ConsumerNum: 1,
+			Overflow:    event.OverflowDropNewest,
+		}),
+		event.WithLane(event.Lane{
+			Name:     "payment",
+			Patterns: []string{"payment.*", "refund.{id}"},
+		}),
+	)
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	var metrics atomic.Int64
+	em.On("metrics.*", event.ListenerFunc(func(e event.Event) error {
+		if metrics.Add(1) == 1 {
+			close(started)
+			<-block
+		}
+		return nil
+	}))
+
+	handled := make(chan string, 10)
+	em.On("*", event.ListenerFunc(func(e event.Event) error {
+		if !strings.HasPrefix(e.Name(), "metrics.") {
+			handled <- e.Name()
+		}
+		return nil
+	}))
+
+	em.Async("metrics.cpu", nil)
+	<-started
+	// the metrics lane is full, more events will be dropped
+	for i := 0; i < 5; i++ {
+		em.Async("metrics.mem", nil)
+	}
+	assert.Eq(t, uint64(4), em.LaneDroppedCount("metrics"))
+	assert.Eq(t, uint64(4), em.DroppedCount())
+
+	// other lanes are not blocked by the metrics lane
+	em.Async("payment.paid", nil)
+	em.Async("refund.1001", nil)
+	em.Async("user.login", nil)
+	var names []string
+	for i := 0; i < 3; i++ {
+		select {
+		case name := <-handled:
+			names = append(names, name)
+		case <-time.After(time.Second):
+			t.Fatal("the events are blocked by the metrics lane")
+		}
+	}
+	assert.Contains(t, names, "payment.paid")
+	assert.Contains(t, names, "refund.1001")
+	assert.Contains(t, names, "user.login")
+	assert.Eq(t, uint64(0), em.LaneDroppedCount("payment"))
+	assert.Eq(t, uint64(0), em.LaneDroppedCount(event.DefaultLane))
+
+	em.SetLaneConsumerNum("payment", 2)
+	close(block)
+	assert.NoErr(t, em.CloseWait())
+	assert.Eq(t, int64(2), metrics.Load())
+	assert.ErrIs(t, em.TryFireAsync(event.New("payment.paid", nil)), event.ErrManagerClosed)
+
+	// invalid lanes
+	assert.Panics(t, func() {
+		event.NewManager("test", event.WithLane(event.Lane{Name: event.DefaultLane}))
+	})
+	assert.Panics(t, func() {
+		event.NewManager("test", event.WithLane(event.Lane{Name: "a"}), event.WithLane(event.Lane{Name: "a"}))
+	})
+	assert.Panics(t, func() {
+		event.NewManager("test", event.WithLane(event.Lane{Name: "a", Patterns: []string{"a..b"}}))
+	})
+}
+
+func TestManager_Lanes_matcher(t *testing.T) {
+	em := event.NewManager("test",
+		event.WithMatcher(event.NewMQTTMatcher()),
+		event.WithLane(event.Lane{
+			Name:        "sensors",
+			Patterns:    []string{"sensors/#"},
+			ChannelSize: 1,
+			ConsumerNum: 1,
+			Overflow:    event.OverflowDropNewest,
+		}),
+	)
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	var calls atomic.Int64
+	em.On("sensors/+/temp", event.ListenerFunc(func(e event.Event) error {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-block
+		}
+		return nil
+	}))
+
+	em.FireAsync(event.New("sensors/kitchen/temp", nil))
+	<-started
+	em.FireAsync(event.New("sensors/kitchen/temp", nil))
+	// dropped by the sensors lane
+	em.FireAsync(event.New("sensors/kitchen/temp", nil))
+	assert.Eq(t, uint64(1), em.LaneDroppedCount("sensors"))
+	assert.Eq(t, uint64(0), em.LaneDroppedCount(event.DefaultLane))
+
+	close(block)
+	assert.NoErr(t, em.CloseWait())
+	assert.Eq(t, int64(2), calls.Load())
+
+	// invalid MQTT pattern
+	assert.Panics(t, func() {
+		event.NewManager("test",
+			event.WithMatcher(event.NewMQTTMatcher()),
+			event.WithLane(event.Lane{Name: "a", Patterns: []string{"a/#/b"}}),
+		)
+	})
+}
+
+type priorityEvent struct {
+	event.BasicEvent
+	priority int
+}
+
+func (e *priorityEvent) Priority() int { return e.priority }
+
+// newBlockedPriorityManager create a manager with one consumer and the priority queue.
+// the consumer is blocked on handle the event "block" until release() called.
+func newBlockedPriorityManager(fns ...event.OptionFn) (em *event.Manager, handled func() []string, release func()) {
+	em = event.NewManager("test", append([]event.OptionFn{event.WithConsumerNum(1)}, fns...)...)
+
+	var mu sync.Mutex
+	var names []string
+	block := make(chan struct{})
+	started := make(chan struct{})
+	em.On("*", event.ListenerFunc(func(e event.Event) error {
+		if e.Name() == "block" {
+			close(started)
+			<-block
+			return nil
+		}
+		mu.Lock()
+		names = append(names, e.Name())
+		mu.Unlock()
+		return nil
+	}))
+
+	em.Async("block", nil)
+	<-started
+
+	handled = func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return names
+	}
+	return em, handled, func() { close(block) }
+}
+
+func TestManager_PriorityQueue(t *testing.T) {
+	em, handled, release := newBlockedPriorityManager(event.UsePriorityQueue(-1))
+
+	em.Async("normal1", nil)
+	em.FireAsyncPriority(event.Low, event.New("low", nil))
+	em.Async("normal2", nil)
+	em.FireAsyncPriority(event.High, event.New("high", nil))
+	pe := &priorityEvent{priority: event.AboveNormal}
+	pe.SetName("above")
+	em.FireAsync(pe)
+	em.FireAsyncPriority(event.Max, event.New("max", nil))
+
+	release()
+	assert.NoErr(t, em.CloseWait())
+	assert.Eq(t, []string{"max", "high", "above", "normal1", "normal2", "low"}, handled())
+}
+
+func TestManager_PriorityQueue_lowFirst(t *testing.T) {
+	em, handled, release := newBlockedPriorityManager(
+		event.UsePriorityQueue(-1),
+		event.WithChannelSize(2),
+		event.WithOverflow(event.OverflowError),
+	)
+
+	// the low event is queued first, it still waits in the queue
+	assert.NoErr(t, em.TryFireAsync(&priorityEvent{BasicEvent: *event.New("low", nil), priority: event.Low}))
+	// the dispatcher must not take it before a consumer is free
+	time.Sleep(10 * time.Millisecond)
+	assert.NoErr(t, em.TryFireAsync(&priorityEvent{BasicEvent: *event.New("max", nil), priority: event.Max}))
+	// the capacity is exactly the channel size
+	assert.ErrIs(t, em.TryFireAsync(event.New("normal", nil)), event.ErrQueueFull)
+
+	release()
+	assert.NoErr(t, em.CloseWait())
+	assert.Eq(t, []string{"max", "low"}, handled())
+}
+
+func TestManager_PriorityQueue_aging(t *testing.T) {
+	em, handled, release := newBlockedPriorityManager(event.UsePriorityQueue(time.Millisecond))
+
+	em.FireAsyncPriority(1, event.New("old", nil))
+	time.Sleep(20 * time.Millisecond)
+	// the old event has waited for 20 aging durations, higher than the new one.
+	em.FireAsyncPriority(5, event.New("new", nil))
+	em.FireAsyncPriority(50, event.New("urgent", nil))
+
+	release()
+	assert.NoErr(t, em.CloseWait())
+	assert.Eq(t, []string{"urgent", "old", "new"}, handled())
+}
+
+func TestManager_PriorityQueue_overflow(t *testing.T) {
+	em, handled, release := newBlockedPriorityManager(
+		event.UsePriorityQueue(-1),
+		event.WithChannelSize(1),
+		event.WithOverflow(event.OverflowDropOldest),
+	)
+
+	em.FireAsyncPriority(event.Low, event.New("low", nil))
+	// replace the low event
+	em.FireAsyncPriority(event.High, event.New("high", nil))
+	// lower than all queued events, drop itself
+	em.FireAsyncPriority(event.Min, event.New("min", nil))
+	assert.Eq(t, uint64(2), em.DroppedCount())
+	assert.NoErr(t, em.TryFireAsync(event.New("normal", nil)))
+	assert.Eq(t, uint64(3), em.DroppedCount())
+
+	release()
+	assert.NoErr(t, em.CloseWait())
+	assert.Eq(t, []string{"high"}, handled())
+
+	// error policy
+	em, handled, release = newBlockedPriorityManager(event.UsePriorityQueue(0), event.WithChannelSize(1))
+	em.Async("normal", nil)
+	assert.ErrIs(t, em.TryFireAsync(event.New("other", nil)), event.ErrQueueFull)
+
+	release()
+	assert.NoErr(t, em.CloseWait())
+	assert.Eq(t, []string{"normal"}, handled())
+}
+
+func TestManager_Retry(t *testing.T) {
+	errTemp := errors.New("temporary error")
+	errFatal := errors.New("fatal error")
+
+	// failing listener, succeed on the nth call
+	failN := func(n int32, calls *int32) event.Listener {
+		return event.ListenerFunc(func(e event.Event) error {
+			if atomic.AddInt32(calls, 1) < n {
+				return errTemp
+			}
+			return nil
+		})
+	}
+
+	policy := event.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	em := event.NewManager("test")
+
+	var calls int32
+	em.On("ok", event.WithRetry(failN(3, &calls), policy))
+	err, _ := em.Fire("ok", nil)
+	assert.NoErr(t, err)
+	assert.Eq(t, int32(3), calls)
+
+	// exhausted
+	calls = 0
+	em.On("fail", event.WithRetry(failN(5, &calls), policy))
+	err, _ = em.Fire("fail", nil)
+	assert.ErrIs(t, err, errTemp)
+	var re *event.RetryError
+	assert.True(t, errors.As(err, &re))
+	assert.Eq(t, 3, re.Attempts)
+	assert.Eq(t, int32(3), calls)
+
+	// not retryable
+	calls = 0
+	em.On("fatal", event.WithRetry(event.ListenerFunc(func(e event.Event) error {
+		atomic.AddInt32(&calls, 1)
+		return errFatal
+	}), event.RetryPolicy{
+		MaxAttempts: 3,
+		Retryable:   func(err error) bool { return err != errFatal },
+	}))
+	err, _ = em.Fire("fatal", nil)
+	assert.Eq(t, errFatal, err)
+	assert.Eq(t, int32(1), calls)
+}
+
+func TestManager_Retry_options(t *testing.T) {
+	em := event.NewManager("test", event.WithRetryPolicy(event.RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+	}))
+
+	var calls, wrapped int32
+	em.On("evt", event.ListenerFunc(func(e event.Event) error {
+		if atomic.AddInt32(&calls, 1)%2 == 1 {
+			return errors.New("temporary error")
+		}
+		return nil
+	}))
+	// use own policy, the manager policy is not applied
+	em.On("evt", event.WithRetry(event.ListenerFunc(func(e event.Event) error {
+		if atomic.AddInt32(&wrapped, 1) < 3 {
+			return errors.New("temporary error")
+		}
+		return nil
+	}), event.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+
+	err, _ := em.Fire("evt", nil)
+	assert.NoErr(t, err)
+	assert.Eq(t, int32(2), calls)
+	assert.Eq(t, int32(3), wrapped)
+
+	// on async consumers
+	em.Async("evt", nil)
+	assert.NoErr(t, em.CloseWait())
+	assert.Eq(t, int32(4), calls)
+}
+
+func TestManager_Retry_ctxCanceled(t *testing.T) {
+	em := event.NewManager("test", event.WithRetryPolicy(event.RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Hour,
+	}))
+
+	var calls int32
+	em.On("evt", event.ListenerFunc(func(e event.Event) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("temporary error")
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err, _ := em.FireCtx(ctx, "evt", nil)
+	assert.ErrIs(t, err, context.DeadlineExceeded)
+	assert.ErrSubMsg(t, err, "temporary error")
+	var re *event.RetryError
+	assert.True(t, errors.As(err, &re))
+	assert.Eq(t, 1, re.Attempts)
+	assert.Eq(t, "temporary error", re.Err.Error())
+	assert.Eq(t, int32(1), calls)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := event.RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	assert.Eq(t, 10*time.Millisecond, p.Backoff(1))
+	assert.Eq(t, 20*time.Millisecond, p.Backoff(2))
+	assert.Eq(t, 40*time.Millisecond, p.Backoff(3))
+	assert.Eq(t, 50*time.Millisecond, p.Backoff(4))
+
+	p.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		d := p.Backoff(2)
+		assert.True(t, d >= 10*time.Millisecond && d <= 20*time.Millisecond)
+	}
+}
+
+func TestManager_Register(t *testing.T) {
+	em := event.NewManager("test")
+
+	var n int
+	fn := event.ListenerFunc(func(e event.Event) error {
+		n++
+		return nil
+	})
+
+	s1 := em.Register("evt1", fn, event.High)
+	s2 := em.Register("evt1", fn)
+	s3 := em.Register("evt2", fn)
+	assert.NotEq(t, s1.ID(), s2.ID())
+	assert.Eq(t, "evt1", s1.EventName())
+	assert.Eq(t, event.High, s1.Priority())
+	assert.Eq(t, event.Normal, s2.Priority())
+	assert.True(t, s1.Active())
+
+	// remove exactly the registration
+	assert.True(t, s1.Unsubscribe())
+	assert.False(t, s1.Active())
+	assert.False(t, s1.Unsubscribe())
+	assert.True(t, s2.Active())
+	assert.Eq(t, 1, em.ListenersCount("evt1"))
+
+	em.MustFire("evt1", nil)
+	em.MustFire("evt2", nil)
+	assert.Eq(t, 2, n)
+
+	// all registrations removed
+	assert.True(t, s2.Unsubscribe())
+	assert.False(t, em.HasListeners("evt1"))
+	assert.True(t, s3.Active())
+
+	// removed by other way
+	em.RemoveListeners("evt2")
+	assert.False(t, s3.Unsubscribe())
+
+	// register once
+	n = 0
+	s4 := em.RegisterOnce("evt3", fn)
+	em.MustFire("evt3", nil)
+	em.MustFire("evt3", nil)
+	assert.Eq(t, 1, n)
+	assert.False(t, s4.Active())
+
+	// unsubscribe before fired
+	s5 := em.RegisterOnce("evt3", fn)
+	assert.True(t, s5.Unsubscribe())
+	em.MustFire("evt3", nil)
+	assert.Eq(t, 1, n)
+}
+
+func TestManager_OnCtx(t *testing.T) {
+	for _, mode := range []uint8{event.ModeSimple, event.ModePath} {
+		em := event.NewManager("test")
+		em.MatchMode = mode
+
+		var n int32
+		fn := event.ListenerFunc(func(e event.Event) error {
+			atomic.AddInt32(&n, 1)
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		sub := em.OnCtx(ctx, "app.user.*", fn)
+		em.On("app.user.*", fn)
+		assert.Eq(t, 2, em.ListenersCount("app.user.*"))
+
+		em.MustFire("app.user.login", nil)
+		assert.Eq(t, int32(2), atomic.LoadInt32(&n))
+
+		// skipped and removed on dispatch, even not removed by the ctx callback.
+		cancel()
+		em.MustFire("app.user.login", nil)
+		assert.Eq(t, int32(3), atomic.LoadInt32(&n))
+		assert.False(t, sub.Active())
+		assert.Eq(t, 1, em.ListenersCount("app.user.*"))
+
+		// the ctx is done, not registered
+		sub = em.OnCtx(ctx, "app.user.login", fn)
+		assert.False(t, sub.Active())
+		assert.False(t, em.HasListeners("app.user.login"))
+
+		// once
+		ctx, cancel = context.WithCancel(context.Background())
+		em.OnceCtx(ctx, "app.user.logout", fn)
+		em.MustFire("app.user.logout", nil)
+		em.MustFire("app.user.logout", nil)
+		// once listener and the "app.user.*" listener
+		assert.Eq(t, int32(6), atomic.LoadInt32(&n))
+		assert.False(t, em.HasListeners("app.user.logout"))
+		cancel()
+	}
+}
+
+// fakeClock the clock for test the listener active window
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestManager_OnWith_window(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	em := event.NewManager("test", event.WithClock(clock.Now))
+
+	var names []string
+	promo := event.ListenerFunc(func(e event.Event) error {
+		names = append(names, "promo")
+		return nil
+	})
+	sub := em.OnWith("order.paid", promo,
+		event.ActiveFrom(clock.Now().Add(time.Hour)),
+		event.ActiveUntil(clock.Now().Add(2*time.Hour)),
+		event.WithPriority(event.High),
+	)
+	em.On("order.paid", event.ListenerFunc(func(e event.Event) error {
+		names = append(names, "normal")
+		return nil
+	}))
+	assert.Eq(t, event.High, sub.Priority())
+
+	// not active yet
+	em.MustFire("order.paid", nil)
+	assert.Eq(t, []string{"normal"}, names)
+	assert.True(t, sub.Active())
+
+	clock.Add(time.Hour)
+	names = names[:0]
+	em.MustFire("order.paid", nil)
+	assert.Eq(t, []string{"promo", "normal"}, names)
+
+	// expired, removed on dispatch
+	clock.Add(time.Hour)
+	names = names[:0]
+	em.MustFire("order.paid", nil)
+	assert.Eq(t, []string{"normal"}, names)
+	assert.False(t, sub.Active())
+}
+
+func TestManager_OnWith_TTL(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	em := event.NewManager("test", event.WithClock(clock.Now))
+
+	s1 := em.OnWith("debug.sql", event.ListenerFunc(emptyListener), event.TTL(10*time.Minute))
+	s2 := em.OnWith("debug.http", event.ListenerFunc(emptyListener), event.TTL(time.Hour))
+	assert.Eq(t, 0, em.SweepExpired())
+
+	// removed by the lazy sweep on fire other event
+	clock.Add(10 * time.Minute)
+	em.MustFire("app.start", nil)
+	assert.False(t, s1.Active())
+	assert.False(t, em.HasListeners("debug.sql"))
+	assert.True(t, s2.Active())
+
+	// manual sweep
+	clock.Add(time.Hour)
+	assert.Eq(t, 1, em.SweepExpired())
+	assert.False(t, em.HasListeners("debug.http"))
+}
+
+func TestManager_Use(t *testing.T) {
+	var ss []string
+	mw := func(name string) event.Middleware {
+		return func(next event.Listener) event.Listener {
+			return event.ListenerFunc(func(e event.Event) error {
+				ss = append(ss, name+">")
+				err := next.Handle(e)
+				ss = append(ss, "<"+name)
+				return err
+			})
+		}
+	}
+
+	em := event.NewManager("test")
+	em.Use(mw("g1"), mw("g2"))
+	em.OnWith("app.user.login", event.ListenerFunc(func(e event.Event) error {
+		ss = append(ss, "login")
+		return nil
+	}), event.WithMiddleware(mw("l1"), mw("l2")))
+	em.On("*", event.ListenerFunc(func(e event.Event) error {
+		ss = append(ss, "all")
+		return nil
+	}), event.Low)
+
+	em.MustFire("app.user.login", nil)
+	assert.Eq(t, []string{
+		"g1>", "g2>", "l1>", "l2>", "login", "<l2", "<l1", "<g2", "<g1",
+		"g1>", "g2>", "all", "<g2", "<g1",
+	}, ss)
+
+	// on ModePath and type-keyed listeners
+	em.MatchMode = event.ModePath
+	em.On("app.**", event.ListenerFunc(func(e event.Event) error {
+		ss = append(ss, "path")
+		return nil
+	}), event.High)
+	event.Handle(em, func(ctx context.Context, v userCreated) error {
+		ss = append(ss, "typed")
+		return nil
+	})
+
+	ss = ss[:0]
+	em.MustFire("app.user.logout", nil)
+	assert.NoErr(t, em.Publish(userCreated{}))
+	assert.Eq(t, []string{
+		"g1>", "g2>", "path", "<g2", "<g1",
+		"g1>", "g2>", "all", "<g2", "<g1",
+		"g1>", "g2>", "typed", "<g2", "<g1",
+	}, ss)
+
+	// cleared by Reset
+	em.Reset()
+	ss = ss[:0]
+	em.On("evt", event.ListenerFunc(func(e event.Event) error {
+		ss = append(ss, "evt")
+		return nil
+	}))
+	em.MustFire("evt", nil)
+	assert.Eq(t, []string{"evt"}, ss)
+
+	assert.Panics(t, func() {
+		em.Use(nil)
+	})
+}
+
+func TestManager_Use_intercept(t *testing.T) {
+	errDenied := errors.New("permission denied")
+	auth := func(next event.Listener) event.Listener {
+		return event.ListenerFunc(func(e event.Event) error {
+			if e.Get("admin") != true {
+				return errDenied
+			}
+			return next.Handle(e)
+		})
+	}
+
+	var calls int
+	em := event.NewManager("test", event.WithPanicPolicy(event.PanicStop))
+	em.OnWith("admin.user.deleted", event.ListenerFunc(func(e event.Event) error {
+		calls++
+		return nil
+	}), event.WithMiddleware(auth))
+
+	err, _ := em.Fire("admin.user.deleted", event.M{"admin": false})
+	assert.ErrIs(t, err, errDenied)
+	err, _ = em.Fire("admin.user.deleted", event.M{"admin": true})
+	assert.NoErr(t, err)
+	assert.Eq(t, 1, calls)
+
+	// the panic of middleware is recovered by the panic policy
+	em.Use(func(next event.Listener) event.Listener {
+		return event.ListenerFunc(func(e event.Event) error {
+			panic("middleware panic")
+		})
+	})
+	err, _ = em.Fire("admin.user.deleted", event.M{"admin": true})
+	var pe *event.ListenerPanicError
+	assert.True(t, errors.As(err, &pe))
+	assert.Eq(t, 1, calls)
+}
+
+func TestManager_Use_buildOnce(t *testing.T) {
+	builds := map[string]int{}
+	mw := func(name string) event.Middleware {
+		return func(next event.Listener) event.Listener {
+			builds[name]++
+			return next
+		}
+	}
+
+	var calls int
+	em := event.NewManager("test")
+	em.Use(mw("g1"))
+	em.OnWith("app.user.login", event.ListenerFunc(func(e event.Event) error {
+		calls++
+		return nil
+	}), event.WithMiddleware(mw("l1")))
+
+	for i := 0; i < 3; i++ {
+		em.MustFire("app.user.login", nil)
+	}
+	assert.Eq(t, 3, calls)
+	assert.Eq(t, map[string]int{"g1": 1, "l1": 1}, builds)
+
+	// the global chain is rebuilt once after Use()
+	em.Use(mw("g2"))
+	for i := 0; i < 3; i++ {
+		em.MustFire("app.user.login", nil)
+	}
+	assert.Eq(t, 6, calls)
+	assert.Eq(t, map[string]int{"g1": 2, "g2": 1, "l1": 1}, builds)
+}
+
 func TestManager_Once(t *testing.T) {
 	em := event.NewManager("test")
 
@@ -391,3 +1573,403 @@ func TestManager_Once(t *testing.T) {
 	em.Trigger("evt1", nil)
 	assert.False(t, em.HasListeners("evt1"))
 }
+
+func TestManager_Once_concurrent(t *testing.T) {
+	em := event.NewManager("test")
+
+	var n int32
+	em.Once("evt1", event.ListenerFunc(func(e event.Event) error {
+		atomic.AddInt32(&n, 1)
+		time.Sleep(time.Millisecond)
+		return nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			em.MustFire("evt1", nil)
+		}()
+	}
+	wg.Wait()
+	assert.Eq(t, int32(1), n)
+	assert.False(t, em.HasListeners("evt1"))
+}
+
+func TestManager_Times(t *testing.T) {
+	em := event.NewManager("test")
+
+	var n int32
+	sub := em.Times("evt1", 3, event.ListenerFunc(func(e event.Event) error {
+		atomic.AddInt32(&n, 1)
+		return nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			em.MustFire("evt1", nil)
+		}()
+	}
+	wg.Wait()
+	assert.Eq(t, int32(3), n)
+	assert.False(t, sub.Active())
+
+	assert.Panics(t, func() {
+		em.Times("evt1", 0, event.ListenerFunc(emptyListener))
+	})
+}
+
+func TestManager_Until(t *testing.T) {
+	em := event.NewManager("test")
+
+	var ps []int
+	sub := em.Until("job.progress", event.ListenerFunc(func(e event.Event) error {
+		ps = append(ps, e.Get("percent").(int))
+		return nil
+	}), func(e event.Event) bool {
+		return e.Get("percent") == 100
+	})
+
+	for _, p := range []int{30, 60, 100, 100} {
+		em.MustFire("job.progress", event.M{"percent": p})
+	}
+	assert.Eq(t, []int{30, 60, 100}, ps)
+	assert.False(t, sub.Active())
+	assert.False(t, em.HasListeners("job.progress"))
+
+	assert.Panics(t, func() {
+		em.Until("job.progress", event.ListenerFunc(emptyListener), nil)
+	})
+}
+
+func TestManager_concurrentRegisterAndFire(t *testing.T) {
+	em := event.NewManager("test", event.WithConsumerNum(4))
+
+	var calls atomic.Int64
+	newListener := func() event.ListenerFunc {
+		return func(e event.Event) error {
+			calls.Add(1)
+			return nil
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(5)
+		name := fmt.Sprintf("app.evt%d", i%3)
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				l := newListener()
+				em.On(name, l, j%3)
+				em.On("app.*", l)
+				em.RemoveListener(name, l)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				em.Once(name, newListener())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, _ = em.Fire(name, nil)
+				em.HasListeners(name)
+				em.ListenersCount(name)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				em.FireAsync(event.New(name, nil))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				em.RemoveListeners("app.*")
+				_ = em.Listeners()
+				_ = em.ListenedNames()
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.NoErr(t, em.CloseWait())
+	assert.Gt(t, calls.Load(), int64(0))
+}
+
+func TestManager_concurrentFire_pathMode(t *testing.T) {
+	em := event.NewManager("test", event.UsePathMode)
+
+	var calls atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				em.On("db.**", event.ListenerFunc(func(e event.Event) error {
+					calls.Add(1)
+					return nil
+				}), j)
+				em.On(fmt.Sprintf("db.user%d.*", i), event.ListenerFunc(emptyListener))
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				em.MustFire("db.user1.add", nil)
+			}
+		}()
+	}
+
+	wg.Wait()
+	em.MustFire("db.user1.add", nil)
+	assert.Gt(t, calls.Load(), int64(0))
+}
+
+func TestManager_concurrentReset_pathMode(t *testing.T) {
+	em := event.NewManager("test", event.UsePathMode)
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			em.On("db.**", event.ListenerFunc(emptyListener))
+			em.Reset()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			em.MustFire("db.user.add", nil)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			if lq := em.ListenersByName("db.**"); lq != nil {
+				_ = lq.Len()
+				_ = lq.Items()
+			}
+			for _, lq := range em.Listeners() {
+				_ = lq.Len()
+			}
+		}
+	}()
+	wg.Wait()
+
+	// the returned queue is a copy
+	em.On("db.**", event.ListenerFunc(emptyListener))
+	lq := em.ListenersByName("db.**")
+	em.On("db.**", event.ListenerFunc(emptyListener))
+	assert.Eq(t, 1, lq.Len())
+	assert.Eq(t, 2, em.ListenersCount("db.**"))
+}
+
+func TestManager_Fire_reentrant(t *testing.T) {
+	em := event.NewManager("test", event.EnableLock(true))
+
+	buf := new(bytes.Buffer)
+	em.On("app.evt1", event.ListenerFunc(func(e event.Event) error {
+		buf.WriteString("evt1|")
+		// register and fire other event in the listener
+		em.Once("app.evt3", event.ListenerFunc(func(e event.Event) error {
+			buf.WriteString("evt3|")
+			return nil
+		}))
+		err, _ := em.Fire("app.evt2", nil)
+		return err
+	}))
+	em.On("app.evt2", event.ListenerFunc(func(e event.Event) error {
+		buf.WriteString("evt2|")
+		err, _ := em.Fire("app.evt3", nil)
+		return err
+	}))
+
+	done := make(chan error, 1)
+	go func() {
+		err, _ := em.Fire("app.evt1", nil)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.NoErr(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("re-entrant fire is deadlocked")
+	}
+	assert.Eq(t, "evt1|evt2|evt3|", buf.String())
+	assert.False(t, em.HasListeners("app.evt3"))
+}
+
+func TestListenerQueue_snapshot(t *testing.T) {
+	em := event.NewManager("test")
+	em.On("evt1", event.ListenerFunc(emptyListener), event.Low)
+
+	items := em.ListenersByName("evt1").Items()
+	assert.Len(t, items, 1)
+
+	em.On("evt1", event.ListenerFunc(emptyListener), event.High)
+	assert.Len(t, items, 1)
+	assert.Eq(t, event.Low, items[0].Priority)
+
+	newItems := em.ListenersByName("evt1").Items()
+	assert.Len(t, newItems, 2)
+	assert.Eq(t, event.High, newItems[0].Priority)
+}
+
+func TestListenerQueue_Push(t *testing.T) {
+	lq := &event.ListenerQueue{}
+	assert.True(t, lq.IsEmpty())
+
+	pvs := []int{event.Normal, event.Low, event.High, event.Normal, event.High, event.Low, event.Normal}
+	for i, pv := range pvs {
+		lq.Push(&event.ListenerItem{Priority: pv, Listener: &testListener{fmt.Sprint(i)}})
+	}
+	assert.Eq(t, len(pvs), lq.Len())
+
+	var ss []string
+	for _, li := range lq.Sort().Items() {
+		ss = append(ss, fmt.Sprintf("%d:%s", li.Priority, li.Listener.(*testListener).userData))
+	}
+	assert.Eq(t, "200:2 200:4 0:0 0:3 0:6 -200:1 -200:5", strings.Join(ss, " "))
+}
+
+func TestManager_Fire_samePriorityOrder(t *testing.T) {
+	em := event.NewManager("test")
+
+	buf := new(bytes.Buffer)
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprint(i)
+		pv := event.Normal
+		if i%3 == 0 {
+			pv = event.High
+		}
+
+		em.On("evt1", event.ListenerFunc(func(e event.Event) error {
+			buf.WriteString(name)
+			return nil
+		}), pv)
+	}
+
+	em.MustFire("evt1", nil)
+	assert.Eq(t, "0369124578", buf.String())
+}
+
+func TestManager_Fire_pathMode_priorityOrder(t *testing.T) {
+	em := event.NewManager("test", event.UsePathMode)
+
+	buf := new(bytes.Buffer)
+	newListener := func(s string) event.Listener {
+		return event.ListenerFunc(func(e event.Event) error {
+			buf.WriteString(s + "|")
+			return nil
+		})
+	}
+
+	em.On("db.user.add", newListener("add-low"), event.Low)
+	em.On("db.user.*", newListener("user-normal"))
+	em.On("db.**", newListener("all-high"), event.High)
+	em.On("db.user.add", newListener("add-normal"))
+	em.On("*", newListener("wildcard-high"), event.High)
+	em.On("db.**", newListener("all-normal"))
+
+	want := "all-high|wildcard-high|user-normal|add-normal|all-normal|add-low|"
+	for i := 0; i < 20; i++ {
+		em.MustFire("db.user.add", nil)
+		assert.Eq(t, want, buf.String())
+		buf.Reset()
+	}
+}
+
+func TestManager_Fire_pathMode_middleAllNode(t *testing.T) {
+	em := event.NewManager("test", event.UsePathMode)
+
+	var names []string
+	em.On("app.**.saved", event.ListenerFunc(func(e event.Event) error {
+		names = append(names, e.Name())
+		return nil
+	}))
+
+	for _, name := range []string{"app.saved", "app.user.saved", "app.user.profile.saved", "app.user.profile", "app.saved.user"} {
+		em.MustFire(name, nil)
+	}
+	assert.Eq(t, []string{"app.saved", "app.user.saved", "app.user.profile.saved"}, names)
+
+	// malformed pattern
+	assert.Panics(t, func() {
+		em.On("app.**x.saved", event.ListenerFunc(emptyListener))
+	})
+	assert.Panics(t, func() {
+		em.On("app..saved", event.ListenerFunc(emptyListener))
+	})
+}
+
+func TestManager_On_simpleMode(t *testing.T) {
+	em := event.NewManager("test")
+
+	// ModeSimple keeps the loose name check
+	var n int
+	em.On("app..x", event.ListenerFunc(func(e event.Event) error {
+		n++
+		return nil
+	}))
+	em.MustFire("app..x", nil)
+	assert.Eq(t, 1, n)
+
+	// named params are rejected on ModeSimple
+	assert.Panics(t, func() {
+		em.On("tenant.{id}", event.ListenerFunc(emptyListener))
+	})
+}
+
+func TestManager_Fire_pathMode_namedParams(t *testing.T) {
+	em := event.NewManager("test", event.UsePathMode)
+
+	var ss []string
+	em.On("tenant.{tenant}.order.{action}", event.ListenerFunc(func(e event.Event) error {
+		ps := event.PathParams(e)
+		ss = append(ss, ps["tenant"]+":"+ps["action"])
+		return nil
+	}), event.High)
+	em.On("tenant.{id}.**", event.ListenerFunc(func(e event.Event) error {
+		ss = append(ss, "id="+event.PathParams(e)["id"])
+		return nil
+	}))
+	em.On("tenant.*.order.*", event.ListenerFunc(func(e event.Event) error {
+		ss = append(ss, fmt.Sprint("no params:", len(event.PathParams(e))))
+		return nil
+	}), event.Low)
+
+	em.MustFire("tenant.t1.order.paid", nil)
+	assert.Eq(t, []string{"t1:paid", "id=t1", "no params:0"}, ss)
+
+	// with context
+	ss = ss[:0]
+	err, _ := em.FireCtx(context.Background(), "tenant.t2.order.created", nil)
+	assert.NoErr(t, err)
+	assert.Eq(t, []string{"t2:created", "id=t2", "no params:0"}, ss)
+
+	// remove one of same shape patterns
+	em.RemoveListeners("tenant.*.order.*")
+	ss = ss[:0]
+	em.MustFire("tenant.t3.order.paid", nil)
+	assert.Eq(t, []string{"t3:paid", "id=t3"}, ss)
+
+	// invalid named param node
+	for _, pattern := range []string{"tenant.{id", "tenant.x{id}", "tenant.{1d}", "tenant.{}"} {
+		assert.Panics(t, func() {
+			em.On(pattern, event.ListenerFunc(emptyListener))
+		}, pattern)
+	}
+}
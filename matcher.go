@@ -0,0 +1,281 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Matcher match the fired event name to the listened names(patterns).
+//
+// The Manager delegates event name checking and pattern matching to it.
+// Add and Remove are called with the manager write lock held,
+// Match is called with the read lock held, it can be called concurrently.
+type Matcher interface {
+	// CheckName check and normalize the event name. isPattern is true on add listener.
+	CheckName(name string, isPattern bool) (string, error)
+	// Add a listen pattern, called on the pattern is first listened.
+	Add(pattern string)
+	// Remove a listen pattern, called on all listeners of the pattern are removed.
+	Remove(pattern string)
+	// Match returns the listen patterns matched the event name.
+	//
+	// NOTE: the returned patterns can contain not listened names, they will be ignored.
+	Match(name string) []string
+}
+
+// ParamsMatcher optional interface for Matcher, can capture the named params from event name.
+type ParamsMatcher interface {
+	Matcher
+	// Params returns the named params captured by the pattern from event name.
+	// returns nil if the pattern has no named params.
+	Params(pattern, name string) map[string]string
+}
+
+// WithMatcher set a custom event name Matcher, will override the MatchMode.
+//
+// Usage:
+//
+//	em := NewManager("test", WithMatcher(NewMQTTMatcher()))
+func WithMatcher(m Matcher) OptionFn {
+	return func(o *Options) {
+		o.Matcher = m
+	}
+}
+
+/*************************************************************
+ * region Simple matcher
+ *************************************************************/
+
+// simpleMatcher the matcher for ModeSimple.
+//
+// Listeners are called group by group in the Match() order, not merged by priority.
+type simpleMatcher struct{}
+
+// NewSimpleMatcher create the ModeSimple matcher. see ModeSimple
+func NewSimpleMatcher() Matcher { return simpleMatcher{} }
+
+// CheckName check event name is valid.
+func (simpleMatcher) CheckName(name string, isPattern bool) (string, error) {
+	return goodNameOrErr(name, isPattern)
+}
+
+// Add a listen pattern. nothing to do.
+func (simpleMatcher) Add(string) {}
+
+// Remove a listen pattern. nothing to do.
+func (simpleMatcher) Remove(string) {}
+
+// Match returns the direct name, group name and the Wildcard.
+//
+// eg: "db.user.add" -> "db.user.add", "db.user.*", "*"
+func (simpleMatcher) Match(name string) []string {
+	ps := make([]string, 1, 3)
+	ps[0] = name
+
+	// exists group
+	if pos := strings.LastIndexByte(name, '.'); pos > 0 {
+		groupName := name[:pos+1] + Wildcard // "app.*"
+		if groupName != name {
+			ps = append(ps, groupName)
+		}
+	}
+	return append(ps, Wildcard)
+}
+
+/*************************************************************
+ * region Path matcher
+ *************************************************************/
+
+// pathMatcher the matcher for ModePath. use the segment trie index.
+type pathMatcher struct {
+	trie *patternTrie
+}
+
+// NewPathMatcher create the ModePath matcher. see ModePath
+func NewPathMatcher() Matcher {
+	return &pathMatcher{trie: newPatternTrie(".")}
+}
+
+// CheckName check event name is valid.
+func (m *pathMatcher) CheckName(name string, isPattern bool) (string, error) {
+	return goodPatternOrErr(name, isPattern)
+}
+
+// Add a listen pattern to the trie index
+func (m *pathMatcher) Add(pattern string) { m.trie.Add(pattern) }
+
+// Remove a listen pattern from the trie index
+func (m *pathMatcher) Remove(pattern string) { m.trie.Remove(pattern) }
+
+// Match find matched patterns by the trie index
+func (m *pathMatcher) Match(name string) []string { return m.trie.Match(name) }
+
+// Params returns the named params captured by the pattern. eg: "tenant.{tenant}.order.{action}"
+func (m *pathMatcher) Params(pattern, name string) map[string]string {
+	if !strings.ContainsRune(pattern, '{') {
+		return nil
+	}
+
+	params, _ := matchNodeParams(pattern, name, m.trie.sep)
+	return params
+}
+
+/*************************************************************
+ * region Regex matcher
+ *************************************************************/
+
+// RegexPrefix the prefix of regex listen pattern. eg: `re:^order\.(created|paid)$`
+const RegexPrefix = "re:"
+
+// regexMatcher match the event name by regex patterns.
+type regexMatcher struct {
+	res map[string]*regexp.Regexp
+	// matchAll mark the Wildcard "*" has been added.
+	matchAll bool
+}
+
+// NewRegexMatcher create a regex matcher.
+//
+// Listen patterns:
+//   - start with RegexPrefix "re:" is a regex pattern. eg: `re:^order\.(created|paid)$`
+//   - Wildcard "*" match all event names.
+//   - others match the event name exactly.
+//
+// NOTE: each regex pattern will be checked on fire, the cost is proportional to the number of them.
+func NewRegexMatcher() Matcher {
+	return &regexMatcher{res: make(map[string]*regexp.Regexp)}
+}
+
+// CheckName check event name or regex pattern is valid.
+func (m *regexMatcher) CheckName(name string, isPattern bool) (string, error) {
+	name = strings.TrimSpace(name)
+	if isPattern {
+		if name == Wildcard || name == AllNode {
+			return Wildcard, nil
+		}
+
+		if strings.HasPrefix(name, RegexPrefix) {
+			if _, err := regexp.Compile(name[len(RegexPrefix):]); err != nil {
+				return name, fmt.Errorf("event: invalid regex pattern %q: %v", name, err)
+			}
+			return name, nil
+		}
+	}
+	return goodNameOrErr(name, false)
+}
+
+// Add a listen pattern, compile the regex pattern.
+func (m *regexMatcher) Add(pattern string) {
+	if pattern == Wildcard {
+		m.matchAll = true
+	} else if strings.HasPrefix(pattern, RegexPrefix) {
+		m.res[pattern] = regexp.MustCompile(pattern[len(RegexPrefix):])
+	}
+}
+
+// Remove a listen pattern
+func (m *regexMatcher) Remove(pattern string) {
+	if pattern == Wildcard {
+		m.matchAll = false
+	} else {
+		delete(m.res, pattern)
+	}
+}
+
+// Match returns the event name and all matched regex patterns.
+func (m *regexMatcher) Match(name string) []string {
+	ps := []string{name}
+	if m.matchAll {
+		ps = append(ps, Wildcard)
+	}
+
+	for pattern, re := range m.res {
+		if re.MatchString(name) {
+			ps = append(ps, pattern)
+		}
+	}
+	return ps
+}
+
+/*************************************************************
+ * region MQTT matcher
+ *************************************************************/
+
+// MQTT topic wildcards
+const (
+	MQTTSingleLevel = "+"
+	MQTTMultiLevel  = "#"
+)
+
+// mqttMatcher match the event name like MQTT topic filters.
+type mqttMatcher struct {
+	trie *patternTrie
+}
+
+// NewMQTTMatcher create a MQTT-style matcher. event names are separated by "/"
+//
+// Listen patterns:
+//   - "+" match exactly one level. eg: "home/+/temp" match "home/kitchen/temp"
+//   - "#" match any number of levels, must be the last level.
+//     eg: "home/#" match "home", "home/kitchen", "home/kitchen/temp"
+//   - wildcards do not match the names start with "$". eg: "$SYS/info"
+func NewMQTTMatcher() Matcher {
+	return &mqttMatcher{trie: &patternTrie{
+		sep:        "/",
+		anyTok:     MQTTSingleLevel,
+		allTok:     MQTTMultiLevel,
+		allEndZero: true,
+		root:       &trieNode{},
+	}}
+}
+
+// CheckName check event name(topic) or pattern(topic filter) is valid.
+func (m *mqttMatcher) CheckName(name string, isPattern bool) (string, error) {
+	if name == "" {
+		return "", errors.New("event: the event name cannot be empty")
+	}
+
+	levels := strings.Split(name, "/")
+	for i, level := range levels {
+		if !strings.ContainsAny(level, MQTTSingleLevel+MQTTMultiLevel) {
+			continue
+		}
+
+		if !isPattern {
+			return name, fmt.Errorf("event: name %q is invalid, cannot contain wildcards", name)
+		}
+		if level != MQTTSingleLevel && level != MQTTMultiLevel {
+			return name, fmt.Errorf("event: pattern %q is invalid, wildcard must be a whole level", name)
+		}
+		if level == MQTTMultiLevel && i != len(levels)-1 {
+			return name, fmt.Errorf("event: pattern %q is invalid, %q must be the last level", name, MQTTMultiLevel)
+		}
+	}
+	return name, nil
+}
+
+// Add a listen pattern to the trie index
+func (m *mqttMatcher) Add(pattern string) { m.trie.Add(pattern) }
+
+// Remove a listen pattern from the trie index
+func (m *mqttMatcher) Remove(pattern string) { m.trie.Remove(pattern) }
+
+// Match find matched patterns by the trie index
+func (m *mqttMatcher) Match(name string) []string {
+	ps := m.trie.Match(name)
+	if !strings.HasPrefix(name, "$") {
+		return ps
+	}
+
+	// names start with "$" are not matched by wildcard at first level.
+	n := 0
+	for _, p := range ps {
+		if !strings.HasPrefix(p, MQTTSingleLevel) && !strings.HasPrefix(p, MQTTMultiLevel) {
+			ps[n] = p
+			n++
+		}
+	}
+	return ps[:n]
+}
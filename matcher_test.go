@@ -0,0 +1,136 @@
+package event_test
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/gookit/event"
+	"github.com/gookit/goutil/testutil/assert"
+)
+
+func TestNewRegexMatcher(t *testing.T) {
+	em := event.NewManager("test", event.WithMatcher(event.NewRegexMatcher()))
+
+	buf := new(bytes.Buffer)
+	em.On(`re:^order\.(created|paid)$`, event.ListenerFunc(func(e event.Event) error {
+		buf.WriteString("re:" + e.Name() + "|")
+		return nil
+	}), event.High)
+	em.On("order.paid", event.ListenerFunc(func(e event.Event) error {
+		buf.WriteString("paid|")
+		return nil
+	}))
+	em.On("*", event.ListenerFunc(func(e event.Event) error {
+		buf.WriteString("*|")
+		return nil
+	}), event.Low)
+
+	em.MustFire("order.created", nil)
+	assert.Eq(t, "re:order.created|*|", buf.String())
+	buf.Reset()
+
+	em.MustFire("order.paid", nil)
+	assert.Eq(t, "re:order.paid|paid|*|", buf.String())
+	buf.Reset()
+
+	em.MustFire("order.refund", nil)
+	assert.Eq(t, "*|", buf.String())
+	buf.Reset()
+
+	em.RemoveListeners(`re:^order\.(created|paid)$`)
+	em.MustFire("order.created", nil)
+	assert.Eq(t, "*|", buf.String())
+
+	// invalid regex
+	assert.Panics(t, func() {
+		em.On(`re:^order\.(created`, event.ListenerFunc(emptyListener))
+	})
+	// fire name cannot be a regex
+	err, _ := em.Fire(`re:^order`, nil)
+	assert.Err(t, err)
+}
+
+func TestNewMQTTMatcher(t *testing.T) {
+	em := event.NewManager("test", event.WithMatcher(event.NewMQTTMatcher()))
+
+	var ss []string
+	newListener := func(s string) event.Listener {
+		return event.ListenerFunc(func(e event.Event) error {
+			ss = append(ss, s)
+			return nil
+		})
+	}
+
+	em.On("home/kitchen/temp", newListener("exact"))
+	em.On("home/+/temp", newListener("+"))
+	em.On("home/#", newListener("#"))
+	em.On("#", newListener("all"))
+	em.On("+/+/humidity", newListener("+/+"))
+
+	tests := map[string][]string{
+		"home/kitchen/temp":     {"exact", "+", "#", "all"},
+		"home/bedroom/temp":     {"+", "#", "all"},
+		"home":                  {"#", "all"},
+		"home/bedroom/humidity": {"#", "all", "+/+"},
+		"office/temp":           {"all"},
+		"$SYS/home/humidity":    nil,
+	}
+	for name, want := range tests {
+		ss = ss[:0]
+		em.MustFire(name, nil)
+		sort.Strings(ss)
+		sort.Strings(want)
+		assert.Eq(t, len(want), len(ss), name)
+		if len(want) > 0 {
+			assert.Eq(t, want, ss, name)
+		}
+	}
+
+	// invalid patterns and names
+	for _, pattern := range []string{"home/#/temp", "home/kit+", "home/#x", ""} {
+		assert.Panics(t, func() {
+			em.On(pattern, newListener("invalid"))
+		}, pattern)
+	}
+	err, _ := em.Fire("home/+/temp", nil)
+	assert.Err(t, err)
+	assert.NoErr(t, em.AddEvent(event.New("home/kitchen/light", nil)))
+}
+
+func TestWithMatcher_afterListened(t *testing.T) {
+	em := event.NewManager("test")
+
+	var n int
+	em.On("app.**.saved", event.ListenerFunc(func(e event.Event) error {
+		n++
+		return nil
+	}))
+
+	// ModeSimple
+	em.MustFire("app.user.saved", nil)
+	assert.Eq(t, 0, n)
+
+	// change to ModePath
+	em.WithOptions(event.UsePathMode)
+	em.MustFire("app.user.saved", nil)
+	assert.Eq(t, 1, n)
+
+	// use path matcher instance
+	em.WithOptions(event.WithMatcher(event.NewPathMatcher()))
+	em.MustFire("app.user.profile.saved", nil)
+	assert.Eq(t, 2, n)
+
+	em.Reset()
+	em.MustFire("app.user.profile.saved", nil)
+	assert.Eq(t, 2, n)
+
+	// simple matcher
+	em.WithOptions(event.WithMatcher(event.NewSimpleMatcher()))
+	em.On("app.*", event.ListenerFunc(func(e event.Event) error {
+		n++
+		return nil
+	}))
+	em.MustFire("app.saved", nil)
+	assert.Eq(t, 3, n)
+}
@@ -0,0 +1,96 @@
+package event
+
+// Middleware wrap the listener to intercept the handling. eg: logging, timing, auth checks
+//
+// Usage:
+//
+//	em.Use(func(next event.Listener) event.Listener {
+//		return event.ListenerFunc(func(e event.Event) error {
+//			start := time.Now()
+//			err := next.Handle(e)
+//			log.Println(e.Name(), time.Since(start))
+//			return err
+//		})
+//	})
+type Middleware func(next Listener) Listener
+
+// Use add the global middlewares, they are applied to every listener call.
+//
+// Ordering of a listener call:
+//
+//	global middlewares -> listener middlewares(see WithMiddleware) -> retry(see RetryPolicy) -> listener
+//
+// The middlewares added first are called first(outermost).
+// The chain of each listener is built on its next call after Use(), not on every call.
+func (em *Manager) Use(mws ...Middleware) {
+	for _, mw := range mws {
+		if mw == nil {
+			panic("event: the middleware cannot be empty")
+		}
+	}
+
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	var list []Middleware
+	if old := em.mws.Load(); old != nil {
+		list = append(list, *old...)
+	}
+	list = append(list, mws...)
+	em.mws.Store(&list)
+}
+
+// WithMiddleware add the middlewares for the listener, they are called after the global middlewares.
+//
+// Usage:
+//
+//	em.OnWith("admin.user.deleted", listener, event.WithMiddleware(authCheck))
+func WithMiddleware(mws ...Middleware) ListenOption {
+	for _, mw := range mws {
+		if mw == nil {
+			panic("event: the middleware cannot be empty")
+		}
+	}
+
+	return func(o *listenOptions) {
+		o.mws = append(o.mws, mws...)
+	}
+}
+
+// globalChain the listener wrapped by a snapshot of the global middlewares
+type globalChain struct {
+	mws *[]Middleware
+	l   Listener
+}
+
+// buildHandler wrap the listener by its middlewares and the retry, called once on registration.
+func (em *Manager) buildHandler(li *ListenerItem) {
+	l := ListenerFunc(func(e Event) error {
+		return em.retryCall(e, li.Listener)
+	})
+	li.handler = chain(l, li.mws)
+}
+
+// handlerOf get the listener wrapped by all middlewares. the global chain is only rebuilt after Use().
+func (em *Manager) handlerOf(li *ListenerItem) Listener {
+	mws := em.mws.Load()
+	if mws == nil {
+		return li.handler
+	}
+
+	if gc := li.state.global.Load(); gc != nil && gc.mws == mws {
+		return gc.l
+	}
+
+	l := chain(li.handler, *mws)
+	li.state.global.Store(&globalChain{mws: mws, l: l})
+	return l
+}
+
+// chain wrap the listener by the middlewares, the first middleware is the outermost.
+func chain(l Listener, mws []Middleware) Listener {
+	for i := len(mws) - 1; i >= 0; i-- {
+		l = mws[i](l)
+	}
+	return l
+}
@@ -0,0 +1,423 @@
+package event
+
+import (
+	"container/heap"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// eventQueue the buffered queue of the events fired by channel.
+//
+// Events without partition key are pushed to the shared channel, consumed by any consumer.
+// Events with a partition key are pushed to the channel of one consumer by the key hash,
+// so the events with same key are consumed sequentially.
+//
+// It is safe to push events after the queue is closed, ErrManagerClosed will be returned.
+type eventQueue struct {
+	ch chan Event
+	// keyed the channel of each consumer, for the events with partition key.
+	keyed []chan Event
+	// mu guards the channels on close, push holds the read lock.
+	mu     sync.RWMutex
+	closed bool
+	// done is closed on close, for wake up the blocked pushes.
+	done chan struct{}
+	once sync.Once
+	// dropped the counter of dropped events
+	dropped *atomic.Uint64
+	// pq the priority queue for the events without partition key. nil for FIFO
+	pq *priorityQueue
+	// workers the consumers of the queue
+	workers sync.WaitGroup
+
+	// smu guards the pending and stopped
+	smu sync.Mutex
+	// pending the number of events in the queue, not taken by consumers.
+	pending int
+	// stopped mark the queue is stopped, the rest events will be discarded.
+	stopped bool
+}
+
+func newEventQueue(size, consumerNum int, dropped *atomic.Uint64) *eventQueue {
+	q := &eventQueue{
+		ch:      make(chan Event, size),
+		keyed:   make([]chan Event, consumerNum),
+		done:    make(chan struct{}),
+		dropped: dropped,
+	}
+
+	for i := range q.keyed {
+		q.keyed[i] = make(chan Event, size)
+	}
+	return q
+}
+
+// newPriorityEventQueue create a queue, the events without partition key
+// are consumed by priority from a heap. see priorityQueue
+func newPriorityEventQueue(size, consumerNum int, aging time.Duration, dropped *atomic.Uint64) *eventQueue {
+	q := newEventQueue(size, consumerNum, dropped)
+	// consumers ask for an event by pq.ready, then receive it from the unbuffered channel.
+	q.ch = make(chan Event)
+	q.pq = newPriorityQueue(size, aging)
+	go q.pq.dispatch(q.ch)
+	return q
+}
+
+// push an event to the queue by the overflow policy.
+//
+// if the key is not empty, push to the keyed channel selected by the key hash.
+// the priority is used on the priority queue, for the events without key.
+func (q *eventQueue) push(key string, priority int, e Event, policy uint8, timeout time.Duration) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrManagerClosed
+	}
+
+	ch := q.ch
+	if key != "" {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(key))
+		ch = q.keyed[h.Sum32()%uint32(len(q.keyed))]
+	}
+
+	var err error
+	if key == "" && q.pq != nil {
+		err = q.pushPriority(priority, e, policy, timeout)
+	} else {
+		err = q.send(ch, e, policy, timeout)
+	}
+
+	if err == nil {
+		q.addPending(1)
+	}
+	return err
+}
+
+// send the event to the channel by the overflow policy.
+func (q *eventQueue) send(ch chan Event, e Event, policy uint8, timeout time.Duration) error {
+	switch policy {
+	case OverflowBlockTimeout:
+		if timeout <= 0 {
+			break
+		}
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case ch <- e:
+			return nil
+		case <-q.done:
+			return ErrManagerClosed
+		case <-timer.C:
+			q.dropped.Add(1)
+			return ErrQueueFull
+		}
+	case OverflowDropNewest, OverflowError:
+		select {
+		case ch <- e:
+			return nil
+		default:
+			q.dropped.Add(1)
+			return ErrQueueFull
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case ch <- e:
+				return nil
+			default:
+			}
+
+			// queue is full, drop the oldest event.
+			select {
+			case <-ch:
+				q.dropped.Add(1)
+				q.addPending(-1)
+			default:
+			}
+		}
+	}
+
+	// OverflowBlock
+	select {
+	case ch <- e:
+		return nil
+	case <-q.done:
+		return ErrManagerClosed
+	}
+}
+
+// pushPriority push the event to the priority queue by the overflow policy.
+//
+// On OverflowDropOldest, the event will be consumed last(lowest priority, newest) is dropped.
+func (q *eventQueue) pushPriority(priority int, e Event, policy uint8, timeout time.Duration) error {
+	slots := q.pq.slots
+
+	switch policy {
+	case OverflowBlockTimeout:
+		if timeout <= 0 {
+			break
+		}
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case slots <- struct{}{}:
+			q.pq.push(priority, e)
+			return nil
+		case <-q.done:
+			return ErrManagerClosed
+		case <-timer.C:
+			q.dropped.Add(1)
+			return ErrQueueFull
+		}
+	case OverflowDropNewest, OverflowError:
+		select {
+		case slots <- struct{}{}:
+			q.pq.push(priority, e)
+			return nil
+		default:
+			q.dropped.Add(1)
+			return ErrQueueFull
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case slots <- struct{}{}:
+				q.pq.push(priority, e)
+				return nil
+			default:
+			}
+
+			// queue is full, replace the last event with the new one.
+			if q.pq.replaceLast(priority, e) {
+				q.dropped.Add(1)
+				q.addPending(-1)
+				return nil
+			}
+		}
+	}
+
+	// OverflowBlock
+	select {
+	case slots <- struct{}{}:
+		q.pq.push(priority, e)
+		return nil
+	case <-q.done:
+		return ErrManagerClosed
+	}
+}
+
+func (q *eventQueue) addPending(n int) {
+	q.smu.Lock()
+	q.pending += n
+	q.smu.Unlock()
+}
+
+// take mark a received event is taken by the consumer.
+// returns false if the queue is stopped, the event should be discarded.
+func (q *eventQueue) take() bool {
+	q.smu.Lock()
+	defer q.smu.Unlock()
+
+	if q.stopped {
+		return false
+	}
+	q.pending--
+	return true
+}
+
+// stop the queue, the rest events will be discarded by consumers.
+// returns the number of the events not taken.
+func (q *eventQueue) stop() int {
+	q.smu.Lock()
+	defer q.smu.Unlock()
+
+	q.stopped = true
+	return q.pending
+}
+
+func (q *eventQueue) isClosed() bool {
+	select {
+	case <-q.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// close the queue, deny to push new event. the queued events can still be consumed.
+func (q *eventQueue) close() {
+	q.once.Do(func() {
+		// wake up the blocked pushes, then they will release the read lock.
+		close(q.done)
+
+		q.mu.Lock()
+		q.closed = true
+		if q.pq != nil {
+			// the q.ch will be closed after the priority queue drained.
+			q.pq.close()
+		} else {
+			close(q.ch)
+		}
+		for _, ch := range q.keyed {
+			close(ch)
+		}
+		q.mu.Unlock()
+	})
+}
+
+// priorityItem an event in the priority queue
+type priorityItem struct {
+	e Event
+	// rank the priority with aging, higher is first.
+	rank int64
+	// seq the push sequence, keep FIFO on same rank.
+	seq uint64
+}
+
+// priorityHeap implements the heap.Interface, the max rank is at the top.
+type priorityHeap []*priorityItem
+
+func (h priorityHeap) Len() int { return len(h) }
+
+func (h priorityHeap) Less(i, j int) bool {
+	if h[i].rank != h[j].rank {
+		return h[i].rank > h[j].rank
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h priorityHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *priorityHeap) Push(x any) { *h = append(*h, x.(*priorityItem)) }
+
+func (h *priorityHeap) Pop() any {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return it
+}
+
+// priorityQueue the heap-backed event queue, higher priority events are consumed first.
+//
+// The events stay in the heap until a consumer is free and asks for one by the ready channel,
+// so the priority order and the capacity are kept for all waiting events.
+//
+// Starvation protection: the priority of a waiting event grows 1 for every aging duration.
+// so the rank of an event is: priority*aging - enqueueTime, it is fixed after pushed.
+type priorityQueue struct {
+	// slots the capacity semaphore, push acquires a slot and dispatch releases it.
+	slots chan struct{}
+	// ready a free consumer asks for the next event
+	ready chan struct{}
+	aging time.Duration
+	base  time.Time
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	items  priorityHeap
+	seq    uint64
+	closed bool
+}
+
+func newPriorityQueue(size int, aging time.Duration) *priorityQueue {
+	pq := &priorityQueue{
+		slots: make(chan struct{}, size),
+		ready: make(chan struct{}),
+		aging: aging,
+		base:  time.Now(),
+	}
+	pq.cond = sync.NewCond(&pq.mu)
+	return pq
+}
+
+func (pq *priorityQueue) newItem(priority int, e Event) *priorityItem {
+	pq.seq++
+	rank := int64(priority)
+	if pq.aging > 0 {
+		rank = rank*int64(pq.aging) - int64(time.Since(pq.base))
+	}
+	return &priorityItem{e: e, rank: rank, seq: pq.seq}
+}
+
+// push an event, the caller must have acquired a slot.
+func (pq *priorityQueue) push(priority int, e Event) {
+	pq.mu.Lock()
+	heap.Push(&pq.items, pq.newItem(priority, e))
+	pq.mu.Unlock()
+	pq.cond.Signal()
+}
+
+// replaceLast replace the event will be consumed last with the new one,
+// or drop the new one if it will be consumed last. returns false if the queue is empty.
+func (pq *priorityQueue) replaceLast(priority int, e Event) bool {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+
+	if len(pq.items) == 0 {
+		return false
+	}
+
+	last := 0
+	for i := 1; i < len(pq.items); i++ {
+		if pq.items.Less(last, i) {
+			last = i
+		}
+	}
+
+	// the new event will be consumed last, drop it.
+	it := pq.newItem(priority, e)
+	if it.rank < pq.items[last].rank {
+		return true
+	}
+
+	pq.items[last] = it
+	heap.Fix(&pq.items, last)
+	return true
+}
+
+// dispatch the events to the out channel by priority, until closed and drained.
+//
+// The top event is popped only after a consumer asks for it, the consumer is waiting on out.
+func (pq *priorityQueue) dispatch(out chan<- Event) {
+	for {
+		pq.mu.Lock()
+		for len(pq.items) == 0 && !pq.closed {
+			pq.cond.Wait()
+		}
+
+		if len(pq.items) == 0 {
+			pq.mu.Unlock()
+			close(out)
+			return
+		}
+		pq.mu.Unlock()
+
+		// wait for a free consumer. the heap cannot be emptied by others meanwhile.
+		<-pq.ready
+
+		pq.mu.Lock()
+		it := heap.Pop(&pq.items).(*priorityItem)
+		pq.mu.Unlock()
+
+		<-pq.slots
+		out <- it.e
+	}
+}
+
+// close the queue, the dispatch will exit after the queue is drained.
+func (pq *priorityQueue) close() {
+	pq.mu.Lock()
+	pq.closed = true
+	pq.mu.Unlock()
+	pq.cond.Broadcast()
+}
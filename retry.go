@@ -0,0 +1,167 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// default initial backoff of the RetryPolicy
+const defaultRetryBackoff = 100 * time.Millisecond
+
+// RetryPolicy the retry policy for failing listeners.
+//
+// Usage:
+//
+//	policy := event.RetryPolicy{MaxAttempts: 3, InitialBackoff: 50 * time.Millisecond, Jitter: 0.2}
+//	em.On("order.paid", event.WithRetry(listener, policy))
+//	// or for all listeners
+//	em := event.NewManager("app", event.WithRetryPolicy(policy))
+type RetryPolicy struct {
+	// MaxAttempts the max attempts of call the listener, include the first call. <= 1 for no retry.
+	MaxAttempts int
+	// InitialBackoff the wait time before the first retry. default: 100ms
+	InitialBackoff time.Duration
+	// MaxBackoff the max wait time between retries. 0 for no limit.
+	MaxBackoff time.Duration
+	// Multiplier the backoff growth factor for each retry. default: 2
+	Multiplier float64
+	// Jitter randomize the backoff by the factor, range: 0-1.
+	// eg: 0.2 the backoff will be randomized in [backoff*0.8, backoff]
+	Jitter float64
+	// Retryable check the error is retryable. nil for all errors are retryable.
+	Retryable func(err error) bool
+}
+
+// Backoff get the wait time before the retry. attempt is the number of failed calls, start from 1.
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	if d <= 0 {
+		d = defaultRetryBackoff
+	}
+
+	mul := p.Multiplier
+	if mul <= 0 {
+		mul = 2
+	}
+
+	fd := float64(d)
+	for i := 1; i < attempt; i++ {
+		fd *= mul
+		if p.MaxBackoff > 0 && fd >= float64(p.MaxBackoff) {
+			fd = float64(p.MaxBackoff)
+			break
+		}
+	}
+
+	if p.Jitter > 0 {
+		fd -= fd * p.Jitter * rand.Float64()
+	}
+	return time.Duration(fd)
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// call the listener to handle the event, retry on error by the policy.
+//
+// The wait between retries will be interrupted if the context of event is done.
+func (p *RetryPolicy) call(e Event, listener Listener) error {
+	var ctx context.Context
+	if ec, ok := e.(ContextAble); ok {
+		ctx = ec.Context()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := listener.Handle(e)
+		if err == nil || !p.retryable(err) {
+			return err
+		}
+
+		if attempt >= p.MaxAttempts {
+			if attempt == 1 {
+				return err
+			}
+			return &RetryError{Attempts: attempt, Err: err}
+		}
+
+		if cerr := sleepCtx(ctx, p.Backoff(attempt)); cerr != nil {
+			return &RetryError{Attempts: attempt, Err: err, Cause: cerr}
+		}
+	}
+}
+
+// RetryError the listener still fails after retries.
+type RetryError struct {
+	// Attempts the number of calls to the listener
+	Attempts int
+	// Err the last error returned by the listener
+	Err error
+	// Cause the error stopped the retries early, eg: the ctx.Err(). nil if all attempts are used.
+	Cause error
+}
+
+// Error message of the retry error
+func (e *RetryError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("event: listener retry stopped after %d attempts: %v: %v", e.Attempts, e.Cause, e.Err)
+	}
+	return fmt.Sprintf("event: listener failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+// Unwrap get the last error of the listener
+func (e *RetryError) Unwrap() error { return e.Err }
+
+// Is check the target is the Cause, the Err is checked by Unwrap().
+func (e *RetryError) Is(target error) bool {
+	return e.Cause != nil && errors.Is(e.Cause, target)
+}
+
+// retryCall call the listener by the Options.RetryPolicy, if set.
+func (em *Manager) retryCall(e Event, l Listener) error {
+	if p := em.RetryPolicy; p != nil {
+		if _, ok := l.(*retryListener); !ok {
+			return p.call(e, l)
+		}
+	}
+	return l.Handle(e)
+}
+
+// retryListener the listener wrapped with a retry policy
+type retryListener struct {
+	listener Listener
+	policy   RetryPolicy
+}
+
+// WithRetry wrap the listener with a retry policy. see RetryPolicy
+//
+// The Options.RetryPolicy will not be applied to the wrapped listener.
+func WithRetry(listener Listener, policy RetryPolicy) Listener {
+	return &retryListener{listener: listener, policy: policy}
+}
+
+// Handle the event, retry on error by the policy.
+func (l *retryListener) Handle(e Event) error {
+	return l.policy.call(e, l.listener)
+}
+
+// sleepCtx sleep the duration, will be interrupted if the context is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if ctx == nil {
+		time.Sleep(d)
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -27,6 +27,26 @@ func Once(name string, listener Listener, priority ...int) {
 	std.Once(name, listener, priority...)
 }
 
+// Times register a listener to the event, it is called at most n times
+func Times(name string, n int, listener Listener, priority ...int) *Subscription {
+	return std.Times(name, n, listener, priority...)
+}
+
+// Until register a listener to the event, it is removed after the predicate first returns true
+func Until(name string, listener Listener, predicate func(e Event) bool, priority ...int) *Subscription {
+	return std.Until(name, listener, predicate, priority...)
+}
+
+// Register a listener to the event, returns the Subscription handle
+func Register(name string, listener Listener, priority ...int) *Subscription {
+	return std.Register(name, listener, priority...)
+}
+
+// RegisterOnce register a listener to the event. trigger once, returns the Subscription handle
+func RegisterOnce(name string, listener Listener, priority ...int) *Subscription {
+	return std.RegisterOnce(name, listener, priority...)
+}
+
 // Listen register a listener to the event
 func Listen(name string, listener Listener, priority ...int) {
 	std.Listen(name, listener, priority...)
@@ -60,8 +80,17 @@ func Async(name string, params M) { std.Async(name, params) }
 // FireAsync fire event by channel
 func FireAsync(e Event) { std.FireAsync(e) }
 
-// FireAsyncCtx async fire event by go channel, and with context TODO need?
-// func FireAsyncCtx(ctx context.Context, e Event)
+// FireAsyncKey async fire event by channel, with a partition key
+func FireAsyncKey(key string, e Event) { std.FireAsyncKey(key, e) }
+
+// FireAsyncPriority async fire event by channel, with the event priority
+func FireAsyncPriority(priority int, e Event) { std.FireAsyncPriority(priority, e) }
+
+// FireAsyncCtx async fire event by channel, and with context
+func FireAsyncCtx(ctx context.Context, e Event) { std.FireAsyncCtx(ctx, e) }
+
+// AsyncCtx async fire event by channel, and with context
+func AsyncCtx(ctx context.Context, name string, params M) { std.AsyncCtx(ctx, name, params) }
 
 // Trigger alias of Fire
 func Trigger(name string, params M) (error, Event) { return std.Fire(name, params) }
@@ -92,6 +121,12 @@ func MustTrigger(name string, params M) Event { return std.MustFire(name, params
 // FireBatch fire multi event at once.
 func FireBatch(es ...any) []error { return std.FireBatch(es...) }
 
+// Publish a Go value as event to the listeners registered by Handle(). see Manager.Publish()
+func Publish(v any) error { return std.Publish(v) }
+
+// PublishCtx publish a Go value as event with context.
+func PublishCtx(ctx context.Context, v any) error { return std.PublishCtx(ctx, v) }
+
 /*************************************************************
  * region Event
  *************************************************************/
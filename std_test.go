@@ -202,6 +202,28 @@ func TestAsync(t *testing.T) {
 	assert.Contains(t, s, "test:val2|")
 }
 
+func TestAsyncCtx(t *testing.T) {
+	event.Reset()
+
+	buf := new(safeBuffer)
+	event.On("test", event.ListenerFunc(func(e event.Event) error {
+		val, _ := e.(event.ContextAble).Context().Value(ctxKey("key")).(string)
+		buf.WriteString(val + "|")
+		return nil
+	}))
+
+	ctx := context.WithValue(context.Background(), ctxKey("key"), "val1")
+	event.AsyncCtx(ctx, "test", nil)
+	event.FireAsyncCtx(ctx, event.New("test", nil))
+
+	// canceled context, will be dropped
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	event.AsyncCtx(cctx, "test", nil)
+	assert.NoError(t, event.CloseWait())
+	assert.Equal(t, "val1|val1|", buf.String())
+}
+
 func TestFire_point_at_end(t *testing.T) {
 	// clear all
 	event.Reset()
@@ -0,0 +1,159 @@
+package event
+
+import (
+	"context"
+	"time"
+)
+
+// Subscription the handle of a registered listener, returned by Register().
+//
+// It removes exactly the registration it created, without holding on to the listener value.
+type Subscription struct {
+	em   *Manager
+	item *ListenerItem
+}
+
+// ID get the unique id of the registration on the manager.
+func (s *Subscription) ID() uint64 { return s.item.seq }
+
+// EventName get the listened event name or pattern.
+func (s *Subscription) EventName() string { return s.item.name }
+
+// Priority get the listener priority
+func (s *Subscription) Priority() int { return s.item.Priority }
+
+// Listener get the registered listener
+func (s *Subscription) Listener() Listener { return s.item.Listener }
+
+// Active check the listener is still registered.
+func (s *Subscription) Active() bool {
+	s.em.mu.RLock()
+	defer s.em.mu.RUnlock()
+
+	if lq, ok := s.em.listeners[s.item.name]; ok {
+		return lq.indexOf(s.item) >= 0
+	}
+	return false
+}
+
+// Unsubscribe remove the registration from the manager.
+// returns false if it has been removed. eg: by RemoveListener(), Reset()
+func (s *Subscription) Unsubscribe() bool {
+	return s.em.removeItem(s.item)
+}
+
+// removeItem remove the listener item by identity. returns false if not found.
+func (em *Manager) removeItem(li *ListenerItem) bool {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	return em.removeItemLocked(li)
+}
+
+// removeItemLocked remove the listener item, must be called with em.mu locked.
+func (em *Manager) removeItemLocked(li *ListenerItem) bool {
+	lq, ok := em.listeners[li.name]
+	if !ok || !lq.removeItem(li) {
+		return false
+	}
+
+	if lq.IsEmpty() {
+		em.deleteListened(li.name)
+	}
+	stopItems([]*ListenerItem{li})
+	return true
+}
+
+// stopItems stop watching the ctx of the removed listener items. must be called with em.mu locked.
+func stopItems(items []*ListenerItem) {
+	for _, li := range items {
+		if li.stop != nil {
+			li.stop()
+		}
+	}
+}
+
+// ListenOption the option for register a listener by OnWith()
+type ListenOption func(o *listenOptions)
+
+// listenOptions the options of register a listener
+type listenOptions struct {
+	priority int
+	// the active window, see ActiveFrom(), ActiveUntil(), TTL()
+	from, until time.Time
+	ttl         time.Duration
+	// mws the listener middlewares. see WithMiddleware()
+	mws []Middleware
+}
+
+// WithPriority set the listener priority. default is Normal
+func WithPriority(priority int) ListenOption {
+	return func(o *listenOptions) {
+		o.priority = priority
+	}
+}
+
+// OnWith register a listener with options, returns the Subscription handle.
+//
+// Usage:
+//
+//	em.OnWith("order.paid", listener, event.WithPriority(event.High), event.TTL(10*time.Minute))
+func (em *Manager) OnWith(name string, listener Listener, opts ...ListenOption) *Subscription {
+	o := &listenOptions{priority: Normal}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	li := &ListenerItem{Priority: o.priority, Listener: listener, mws: o.mws}
+	em.applyWindow(li, o)
+	em.addListenerItem(name, li)
+	return &Subscription{em: em, item: li}
+}
+
+// OnCtx register a listener like On(), it is removed automatically when the ctx is done.
+//
+// No goroutine is started for the subscription. on go1.21+ it is removed by context.AfterFunc,
+// and the listeners of done ctx are always skipped and removed on dispatch.
+// Before go1.21, they are also removed by the lazy sweep on fire events. see SweepExpired()
+//
+// Usage:
+//
+//	// remove the listener on the websocket connection closed
+//	em.OnCtx(conn.Context(), "chat.message", listener)
+func (em *Manager) OnCtx(ctx context.Context, name string, listener Listener, priority ...int) *Subscription {
+	return em.addCtxItem(ctx, name, &ListenerItem{Priority: priorityOf(priority), Listener: listener})
+}
+
+// OnceCtx register a listener like Once(), it is removed automatically when the ctx is done. see OnCtx()
+func (em *Manager) OnceCtx(ctx context.Context, name string, listener Listener, priority ...int) *Subscription {
+	return em.addCtxItem(ctx, name, &ListenerItem{Priority: priorityOf(priority), Listener: listener, limit: 1})
+}
+
+func (em *Manager) addCtxItem(ctx context.Context, name string, li *ListenerItem) *Subscription {
+	if ctx == nil {
+		panic("event: the listener context cannot be nil")
+	}
+
+	li.ctx = ctx
+	sub := &Subscription{em: em, item: li}
+	// the ctx is done, not register it.
+	if ctx.Err() != nil {
+		li.name = name
+		return sub
+	}
+
+	// NOTE: set the stop before the item is added, it may be removed by a fire at once.
+	if ctx.Done() != nil {
+		li.stop = afterFunc(ctx, func() { em.removeItem(li) })
+		// not support auto remove before go1.21, enable the lazy sweep for it.
+		if li.stop == nil {
+			em.windowed.Store(true)
+		}
+	}
+
+	em.addListenerItem(name, li)
+	// the ctx is done before added, the AfterFunc may have run already.
+	if ctx.Err() != nil {
+		em.removeItem(li)
+	}
+	return sub
+}
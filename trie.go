@@ -0,0 +1,252 @@
+package event
+
+import (
+	"path"
+	"strings"
+)
+
+// trieNode a segment node of the patternTrie
+type trieNode struct {
+	// registered patterns end at the node.
+	// multi patterns when use named params. eg: "a.*", "a.{id}"
+	patterns []string
+	// literal segment children. eg: "user"
+	children map[string]*trieNode
+	// glob segment children, matched by path.Match(). eg: "us*"
+	globs map[string]*trieNode
+	// child for the any token segment, match one segment.
+	anyNode *trieNode
+	// child for the all token segment, match zero or more segments.
+	// at the start or end of pattern, match one or more segments.
+	allNode *trieNode
+}
+
+func (n *trieNode) each(fn func(pattern string)) {
+	for _, p := range n.patterns {
+		fn(p)
+	}
+}
+
+func (n *trieNode) isEmpty() bool {
+	return len(n.patterns) == 0 && len(n.children) == 0 && len(n.globs) == 0 && n.anyNode == nil && n.allNode == nil
+}
+
+// patternTrie a segment trie index for the listen patterns.
+//
+// Lookup cost is proportional to the depth of the event name,
+// not to the number of registered patterns.
+//
+// Pattern segments(on ModePath):
+//   - literal segment: "user" match "user"
+//   - AnyNode "*": match any one segment.
+//   - AllNode "**": match zero or more segments in the middle of pattern,
+//     one or more segments at the start or end of pattern.
+//   - glob segment: eg "us*", match one segment by path.Match()
+//   - named param segment: eg "{tenant}", match one segment like AnyNode
+//   - Wildcard "*" as whole pattern: match all event names.
+type patternTrie struct {
+	sep string
+	// anyTok the token match one segment. eg: "*", MQTT "+"
+	anyTok string
+	// allTok the token match multi segments. eg: "**", MQTT "#"
+	allTok string
+	// wildcard the whole pattern match all names. empty for disable.
+	wildcard string
+	// glob allow glob segment. eg: "us*"
+	glob bool
+	// param allow named param segment, it is matched as anyTok. eg: "{tenant}"
+	param bool
+	// allEndZero the allTok at end of pattern can match zero segments. eg: MQTT "a/#" match "a"
+	allEndZero bool
+
+	root *trieNode
+	// matchAll mark the wildcard pattern has been added.
+	matchAll bool
+}
+
+// newPatternTrie create a trie for the ModePath patterns.
+func newPatternTrie(sep string) *patternTrie {
+	return &patternTrie{
+		sep:      sep,
+		anyTok:   AnyNode,
+		allTok:   AllNode,
+		wildcard: Wildcard,
+		glob:     true,
+		param:    true,
+		root:     &trieNode{},
+	}
+}
+
+// Add a pattern to the trie
+func (t *patternTrie) Add(pattern string) {
+	if t.wildcard != "" && pattern == t.wildcard {
+		t.matchAll = true
+		return
+	}
+
+	node := t.root
+	for _, seg := range strings.Split(pattern, t.sep) {
+		node = t.child(node, seg)
+	}
+
+	for _, p := range node.patterns {
+		if p == pattern {
+			return
+		}
+	}
+	node.patterns = append(node.patterns, pattern)
+}
+
+// child get or create the child node for the segment
+func (t *patternTrie) child(n *trieNode, seg string) *trieNode {
+	switch {
+	case seg == t.anyTok, t.isParam(seg):
+		if n.anyNode == nil {
+			n.anyNode = &trieNode{}
+		}
+		return n.anyNode
+	case seg == t.allTok:
+		if n.allNode == nil {
+			n.allNode = &trieNode{}
+		}
+		return n.allNode
+	case t.isGlob(seg):
+		if n.globs == nil {
+			n.globs = make(map[string]*trieNode)
+		}
+		if c, ok := n.globs[seg]; ok {
+			return c
+		}
+		c := &trieNode{}
+		n.globs[seg] = c
+		return c
+	}
+
+	if n.children == nil {
+		n.children = make(map[string]*trieNode)
+	}
+	if c, ok := n.children[seg]; ok {
+		return c
+	}
+	c := &trieNode{}
+	n.children[seg] = c
+	return c
+}
+
+func (t *patternTrie) isGlob(seg string) bool {
+	return t.glob && strings.Contains(seg, t.anyTok)
+}
+
+func (t *patternTrie) isParam(seg string) bool {
+	if t.param {
+		_, ok := paramNodeName(seg)
+		return ok
+	}
+	return false
+}
+
+// Clear all patterns of the trie
+func (t *patternTrie) Clear() {
+	t.root = &trieNode{}
+	t.matchAll = false
+}
+
+// Remove a pattern from the trie, will prune the empty nodes.
+func (t *patternTrie) Remove(pattern string) {
+	if t.wildcard != "" && pattern == t.wildcard {
+		t.matchAll = false
+		return
+	}
+	t.remove(t.root, strings.Split(pattern, t.sep), pattern)
+}
+
+// remove the pattern segments, returns whether the node is empty.
+func (t *patternTrie) remove(n *trieNode, segs []string, pattern string) bool {
+	if len(segs) == 0 {
+		for i, p := range n.patterns {
+			if p == pattern {
+				n.patterns = append(n.patterns[:i:i], n.patterns[i+1:]...)
+				break
+			}
+		}
+		return n.isEmpty()
+	}
+
+	seg := segs[0]
+	switch {
+	case seg == t.anyTok, t.isParam(seg):
+		if n.anyNode != nil && t.remove(n.anyNode, segs[1:], pattern) {
+			n.anyNode = nil
+		}
+	case seg == t.allTok:
+		if n.allNode != nil && t.remove(n.allNode, segs[1:], pattern) {
+			n.allNode = nil
+		}
+	case t.isGlob(seg):
+		if c, ok := n.globs[seg]; ok && t.remove(c, segs[1:], pattern) {
+			delete(n.globs, seg)
+		}
+	default:
+		if c, ok := n.children[seg]; ok && t.remove(c, segs[1:], pattern) {
+			delete(n.children, seg)
+		}
+	}
+	return n.isEmpty()
+}
+
+// Match find all registered patterns matched the event name.
+func (t *patternTrie) Match(name string) []string {
+	var ps []string
+	if t.matchAll {
+		ps = append(ps, t.wildcard)
+	}
+
+	t.match(t.root, strings.Split(name, t.sep), true, func(pattern string) {
+		// a pattern with multi allTok can be matched more than once.
+		for _, p := range ps {
+			if p == pattern {
+				return
+			}
+		}
+		ps = append(ps, pattern)
+	})
+	return ps
+}
+
+// match the name segments. leading is true on the root node.
+func (t *patternTrie) match(n *trieNode, segs []string, leading bool, fn func(pattern string)) {
+	// allTok consume zero or more segments in the middle,
+	// one or more segments at the start or end of pattern.
+	if n.allNode != nil {
+		least := 0
+		if leading {
+			least = 1
+		}
+
+		for i := least; i <= len(segs); i++ {
+			if i < len(segs) {
+				t.match(n.allNode, segs[i:], false, fn)
+			} else if i > 0 || t.allEndZero {
+				n.allNode.each(fn)
+			}
+		}
+	}
+
+	if len(segs) == 0 {
+		n.each(fn)
+		return
+	}
+
+	seg := segs[0]
+	if c, ok := n.children[seg]; ok {
+		t.match(c, segs[1:], false, fn)
+	}
+	if n.anyNode != nil {
+		t.match(n.anyNode, segs[1:], false, fn)
+	}
+	for gs, c := range n.globs {
+		if ok, _ := path.Match(gs, seg); ok {
+			t.match(c, segs[1:], false, fn)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package event
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/gookit/goutil/testutil/assert"
+)
+
+func TestPatternTrie_Match(t *testing.T) {
+	patterns := []string{
+		"db.user.add", "db.user.*", "db.**", "db.*.update", "**.add", "db.us*.del", "app.*.*",
+		"app.**.saved", "app.**.user.**.saved", "**.user.**",
+	}
+
+	tr := newPatternTrie(".")
+	for _, p := range patterns {
+		tr.Add(p)
+	}
+
+	names := []string{
+		"db.user.add", "db.user.del", "db.user.update", "db.order.update",
+		"app.user.add", "app.user", "db", "add", "not-exist",
+		"app.saved", "app.user.saved", "app.user.profile.saved", "app.user.saved.saved",
+		"app.org.user.profile.saved", "user.saved", "app.user",
+	}
+	for _, name := range names {
+		// same result as the linear matchNodePath() scan
+		var want []string
+		for _, p := range patterns {
+			if p == name || matchNodePath(p, name, ".") {
+				want = append(want, p)
+			}
+		}
+
+		got := tr.Match(name)
+		sort.Strings(want)
+		sort.Strings(got)
+		assert.Eq(t, want, got, "event name: "+name)
+	}
+
+	// wildcard
+	tr.Add(Wildcard)
+	assert.Eq(t, []string{Wildcard}, tr.Match("not-exist"))
+	tr.Remove(Wildcard)
+	assert.Empty(t, tr.Match("not-exist"))
+
+	// remove
+	for _, p := range patterns {
+		tr.Remove(p)
+	}
+	tr.Remove("not.exist.pattern")
+	assert.Empty(t, tr.Match("db.user.add"))
+	assert.True(t, tr.root.isEmpty())
+}
+
+func makeBenchPatterns(n int) []string {
+	ps := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		switch i % 4 {
+		case 0:
+			ps = append(ps, fmt.Sprintf("app%d.user.add", i))
+		case 1:
+			ps = append(ps, fmt.Sprintf("app%d.user.*", i))
+		case 2:
+			ps = append(ps, fmt.Sprintf("app%d.**", i))
+		default:
+			ps = append(ps, fmt.Sprintf("app%d.*.update", i))
+		}
+	}
+	return ps
+}
+
+func BenchmarkPatternMatch_linear(b *testing.B) {
+	patterns := makeBenchPatterns(2000)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, p := range patterns {
+			_ = p == "app1001.user.update" || matchNodePath(p, "app1001.user.update", ".")
+		}
+	}
+}
+
+func BenchmarkPatternMatch_trie(b *testing.B) {
+	tr := newPatternTrie(".")
+	for _, p := range makeBenchPatterns(2000) {
+		tr.Add(p)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = tr.Match("app1001.user.update")
+	}
+}
@@ -0,0 +1,211 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+/*************************************************************
+ * region Typed event
+ *************************************************************/
+
+// PayloadAble the event can carry a typed payload. TypedEvent implements it.
+type PayloadAble[T any] interface {
+	Payload() T
+}
+
+// TypedEvent a generic event with a typed payload, instead of the M data.
+type TypedEvent[T any] struct {
+	BasicEvent
+	ContextTrait
+	payload T
+}
+
+// NewTyped create a typed event instance
+func NewTyped[T any](name string, payload T) *TypedEvent[T] {
+	return &TypedEvent[T]{
+		BasicEvent: BasicEvent{name: name, data: make(map[string]any)},
+		payload:    payload,
+	}
+}
+
+// Payload get the typed payload
+func (e *TypedEvent[T]) Payload() T { return e.payload }
+
+// SetPayload set the typed payload
+func (e *TypedEvent[T]) SetPayload(payload T) { e.payload = payload }
+
+// TypedListenerFunc typed listener func definition.
+type TypedListenerFunc[T any] func(ctx context.Context, payload T) error
+
+// TypedListener wrap a typed listener func to the Listener.
+//
+// The listener will be skipped if the event does not carry a payload of type T.
+// eg: a Wildcard listener received an event fired by Fire(name, M)
+func TypedListener[T any](fn TypedListenerFunc[T]) Listener {
+	return ListenerFunc(func(e Event) error {
+		var payload T
+		switch pe := e.(type) {
+		case PayloadAble[T]:
+			payload = pe.Payload()
+		case PayloadAble[any]: // eg: the event published by Publish()
+			v, ok := pe.Payload().(T)
+			if !ok {
+				return nil
+			}
+			payload = v
+		default:
+			return nil
+		}
+
+		ctx := context.Background()
+		if ec, ok := e.(ContextAble); ok {
+			ctx = ec.Context()
+		}
+		return fn(ctx, payload)
+	})
+}
+
+// OnTyped register a typed listener to the event. can setting priority.
+//
+// Usage:
+//
+//	event.OnTyped(em, "user.created", func(ctx context.Context, u User) error {
+//		fmt.Println(u.Name)
+//		return nil
+//	}, event.High)
+func OnTyped[T any](em *Manager, name string, fn TypedListenerFunc[T], priority ...int) {
+	em.On(name, TypedListener(fn), priority...)
+}
+
+// OnceTyped register a typed listener to the event. trigger once.
+func OnceTyped[T any](em *Manager, name string, fn TypedListenerFunc[T], priority ...int) {
+	em.Once(name, TypedListener(fn), priority...)
+}
+
+// FireTyped fire a typed event by name and payload.
+//
+// Usage:
+//
+//	err := event.FireTyped(em, "user.created", User{Name: "inhere"})
+func FireTyped[T any](em *Manager, name string, payload T) error {
+	return FireTypedCtx(em, context.Background(), name, payload)
+}
+
+// FireTypedCtx fire a typed event by name and payload, with context.
+func FireTypedCtx[T any](em *Manager, ctx context.Context, name string, payload T) error {
+	name, err := em.checkName(name, false)
+	if err != nil {
+		return err
+	}
+
+	e := NewTyped(name, payload)
+	e.WithContext(ctx)
+	return em.fireEvent(e)
+}
+
+/*************************************************************
+ * region Type-keyed events
+ *************************************************************/
+
+// Handle register a listener keyed by the Go type T, it will handle the values published by Publish().
+//
+// If T is an interface type, the listener will receive every published value implementing it.
+// Listeners of the value type and the interface types are called by priority,
+// listeners with same priority are called in the registration order.
+//
+// Usage:
+//
+//	event.Handle(em, func(ctx context.Context, evt UserCreated) error {
+//		fmt.Println(evt.ID)
+//		return nil
+//	}, event.High)
+func Handle[T any](em *Manager, fn TypedListenerFunc[T], priority ...int) {
+	if fn == nil {
+		panic("event: the type-keyed listener cannot be empty")
+	}
+
+	em.addTypeListener(typeOf[T](), &ListenerItem{Priority: priorityOf(priority), Listener: TypedListener(fn)})
+}
+
+// HasHandlers check has listeners registered by Handle() for the type T.
+func HasHandlers[T any](em *Manager) bool {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+	return em.typeListeners[typeOf[T]()] != nil
+}
+
+// RemoveHandlers remove all listeners registered by Handle() for the type T.
+func RemoveHandlers[T any](em *Manager) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	if lq, ok := em.typeListeners[typeOf[T]()]; ok {
+		lq.Clear()
+		delete(em.typeListeners, typeOf[T]())
+	}
+}
+
+// typeOf get the reflect.Type of T, works on interface types.
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (em *Manager) addTypeListener(typ reflect.Type, li *ListenerItem) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	em.initItem(li, typ.String())
+
+	if lq, ok := em.typeListeners[typ]; ok {
+		lq.Push(li)
+	} else {
+		em.typeListeners[typ] = (&ListenerQueue{}).Push(li)
+	}
+}
+
+// Publish a Go value as event, dispatch it to the listeners registered by Handle() for its type.
+//
+// The value is wrapped to *TypedEvent[any], event name is the type string. eg: "main.UserCreated"
+// If not found listener, will return nil.
+//
+// NOTE: the value type must be same as the type of Handle[T], eg: *UserCreated will not match UserCreated.
+//
+// Usage:
+//
+//	err := em.Publish(UserCreated{ID: 1})
+func (em *Manager) Publish(v any) error {
+	return em.PublishCtx(context.Background(), v)
+}
+
+// PublishCtx publish a Go value as event with context. see Publish()
+func (em *Manager) PublishCtx(ctx context.Context, v any) error {
+	if v == nil {
+		return errors.New("event: the published value cannot be nil")
+	}
+
+	typ := reflect.TypeOf(v)
+	items := em.typeItems(typ)
+	if len(items) == 0 {
+		return nil
+	}
+
+	e := NewTyped[any](typ.String(), v)
+	e.WithContext(ctx)
+	return em.putRetried(e, em.fireItems(e, items, nil, em.PanicPolicy))
+}
+
+// typeItems find the listeners of the type and all interfaces implemented by it.
+func (em *Manager) typeItems(typ reflect.Type) []*ListenerItem {
+	var groups [][]*ListenerItem
+	em.mu.RLock()
+	for t, lq := range em.typeListeners {
+		if t == typ || (t.Kind() == reflect.Interface && typ.Implements(t)) {
+			groups = append(groups, lq.items)
+		}
+	}
+	em.mu.RUnlock()
+
+	return mergeItems(groups)
+}
@@ -0,0 +1,184 @@
+package event_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/gookit/event"
+	"github.com/gookit/goutil/testutil/assert"
+)
+
+type testUser struct {
+	ID   int
+	Name string
+}
+
+func TestOnTyped_FireTyped(t *testing.T) {
+	em := event.NewManager("test", event.UsePathMode)
+
+	var ss []string
+	event.OnTyped(em, "user.created", func(ctx context.Context, u testUser) error {
+		ss = append(ss, "created:"+u.Name)
+		return nil
+	})
+	event.OnTyped(em, "user.*", func(ctx context.Context, u testUser) error {
+		ss = append(ss, "group:"+u.Name)
+		return nil
+	}, event.High)
+	// listener with other payload type will be skipped
+	event.OnTyped(em, "user.**", func(ctx context.Context, id int) error {
+		ss = append(ss, "int")
+		return nil
+	})
+	// interoperate with normal listener
+	em.On("*", event.ListenerFunc(func(e event.Event) error {
+		te, ok := e.(*event.TypedEvent[testUser])
+		assert.True(t, ok)
+		ss = append(ss, "wildcard:"+te.Payload().Name)
+		return nil
+	}), event.Low)
+
+	err := event.FireTyped(em, "user.created", testUser{ID: 1, Name: "inhere"})
+	assert.NoErr(t, err)
+	assert.Eq(t, []string{"group:inhere", "created:inhere", "wildcard:inhere"}, ss)
+
+	// invalid name
+	assert.Err(t, event.FireTyped(em, "", testUser{}))
+}
+
+type ctxKey string
+
+func TestFireTypedCtx(t *testing.T) {
+	em := event.NewManager("test")
+
+	var traceID string
+	event.OnTyped(em, "order.paid", func(ctx context.Context, amount float64) error {
+		traceID, _ = ctx.Value(ctxKey("trace_id")).(string)
+		if amount <= 0 {
+			return errors.New("invalid amount")
+		}
+		return nil
+	})
+
+	ctx := context.WithValue(context.Background(), ctxKey("trace_id"), "trace-123")
+	assert.NoErr(t, event.FireTypedCtx(em, ctx, "order.paid", 9.9))
+	assert.Eq(t, "trace-123", traceID)
+	assert.Err(t, event.FireTypedCtx(em, ctx, "order.paid", 0.0))
+
+	// canceled context
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrIs(t, event.FireTypedCtx(em, cctx, "order.paid", 9.9), context.Canceled)
+}
+
+func TestOnceTyped(t *testing.T) {
+	em := event.NewManager("test")
+
+	var n int
+	event.OnceTyped(em, "evt1", func(ctx context.Context, v int) error {
+		n += v
+		return nil
+	})
+
+	assert.NoErr(t, event.FireTyped(em, "evt1", 2))
+	assert.NoErr(t, event.FireTyped(em, "evt1", 3))
+	assert.Eq(t, 2, n)
+	assert.False(t, em.HasListeners("evt1"))
+
+	// fire typed event instance
+	event.OnTyped(em, "evt2", func(ctx context.Context, v int) error {
+		n += v
+		return nil
+	})
+	assert.NoErr(t, em.FireEvent(event.NewTyped("evt2", 5)))
+	assert.Eq(t, 7, n)
+}
+
+type userCreated struct {
+	ID int
+}
+
+func (e userCreated) String() string { return "user created: " + strconv.Itoa(e.ID) }
+
+type orderPaid struct {
+	ID int
+}
+
+func TestHandle_Publish(t *testing.T) {
+	em := event.NewManager("test")
+
+	var ss []string
+	event.Handle(em, func(ctx context.Context, evt userCreated) error {
+		ss = append(ss, "user:"+strconv.Itoa(evt.ID))
+		return nil
+	})
+	event.Handle(em, func(ctx context.Context, evt fmt.Stringer) error {
+		ss = append(ss, "stringer:"+evt.String())
+		return nil
+	}, event.High)
+	event.Handle(em, func(ctx context.Context, evt orderPaid) error {
+		ss = append(ss, "order:"+strconv.Itoa(evt.ID))
+		return nil
+	})
+	assert.True(t, event.HasHandlers[userCreated](em))
+	assert.True(t, event.HasHandlers[fmt.Stringer](em))
+	assert.False(t, event.HasHandlers[*userCreated](em))
+
+	// name-based listeners are not triggered
+	em.On("*", event.ListenerFunc(func(e event.Event) error {
+		ss = append(ss, "wildcard")
+		return nil
+	}))
+
+	assert.NoErr(t, em.Publish(userCreated{ID: 1}))
+	assert.Eq(t, []string{"stringer:user created: 1", "user:1"}, ss)
+
+	ss = ss[:0]
+	assert.NoErr(t, em.Publish(orderPaid{ID: 2}))
+	assert.Eq(t, []string{"order:2"}, ss)
+
+	// pointer type not registered
+	ss = ss[:0]
+	assert.NoErr(t, em.Publish(&orderPaid{ID: 3}))
+	assert.Empty(t, ss)
+	assert.Err(t, em.Publish(nil))
+
+	// coexist with name-based fire
+	ss = ss[:0]
+	err, _ := em.Fire("app.start", nil)
+	assert.NoErr(t, err)
+	assert.Eq(t, []string{"wildcard"}, ss)
+
+	event.RemoveHandlers[fmt.Stringer](em)
+	assert.False(t, event.HasHandlers[fmt.Stringer](em))
+	ss = ss[:0]
+	assert.NoErr(t, em.Publish(userCreated{ID: 4}))
+	assert.Eq(t, []string{"user:4"}, ss)
+
+	em.Reset()
+	assert.False(t, event.HasHandlers[userCreated](em))
+}
+
+func TestPublishCtx(t *testing.T) {
+	em := event.NewManager("test")
+
+	var traceID string
+	event.Handle(em, func(ctx context.Context, evt orderPaid) error {
+		traceID, _ = ctx.Value(ctxKey("trace_id")).(string)
+		return errors.New("handle error")
+	}, event.Low)
+	event.Handle(em, func(ctx context.Context, evt orderPaid) error {
+		return nil
+	}, event.Low)
+
+	ctx := context.WithValue(context.Background(), ctxKey("trace_id"), "trace-456")
+	assert.ErrMsg(t, em.PublishCtx(ctx, orderPaid{ID: 1}), "handle error")
+	assert.Eq(t, "trace-456", traceID)
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrIs(t, em.PublishCtx(cctx, orderPaid{ID: 1}), context.Canceled)
+}
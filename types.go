@@ -1,8 +1,11 @@
 package event
 
 import (
+	"context"
 	"reflect"
 	"sort"
+	"sync/atomic"
+	"time"
 )
 
 // There are some default priority constants
@@ -45,9 +48,86 @@ type Subscriber interface {
 }
 
 // ListenerItem storage a event listener and it's priority value.
+//
+// It is a plain value, can be copied before registered. eg: returned by Subscriber.SubscribedEvents()
 type ListenerItem struct {
 	Priority int
 	Listener Listener
+	// seq registration sequence on the manager, use for keep order on same priority.
+	seq uint64
+	// name the listened event name or pattern.
+	name string
+	// ctx the listener is removed when it is done. see Manager.OnCtx()
+	ctx context.Context
+	// stop the auto remove on ctx done, called on the item is removed.
+	stop func() bool
+	// limit the max calls of the listener, 0 for no limit. see Manager.Times()
+	limit int64
+	// until the listener is removed after it returns true. see Manager.Until()
+	until func(e Event) bool
+	// activeFrom, activeUntil the active window of the listener. zero for no limit.
+	activeFrom, activeUntil time.Time
+	// mws the middlewares of the listener. see WithMiddleware()
+	mws []Middleware
+	// handler the listener wrapped by the mws and retry, built on registration.
+	handler Listener
+	// state the runtime state of the registration, created on registration.
+	state *itemState
+}
+
+// itemState the runtime state of a registered listener item, shared by the copies of the item.
+type itemState struct {
+	// calls the number of started calls, for check the limit.
+	calls atomic.Int64
+	// done mark the listener is finished by the limit or until, no more calls.
+	done atomic.Bool
+	// global the handler wrapped by the global middlewares, rebuilt after Manager.Use()
+	global atomic.Pointer[globalChain]
+}
+
+// hasWindow check the listener has an active window.
+func (li *ListenerItem) hasWindow() bool {
+	return !li.activeFrom.IsZero() || !li.activeUntil.IsZero()
+}
+
+// expiredAt check the listener is expired at the time.
+func (li *ListenerItem) expiredAt(now time.Time) bool {
+	return !li.activeUntil.IsZero() && !now.Before(li.activeUntil)
+}
+
+// inactiveAt check the listener is not active yet at the time.
+func (li *ListenerItem) inactiveAt(now time.Time) bool {
+	return !li.activeFrom.IsZero() && now.Before(li.activeFrom)
+}
+
+// acquire a call of the listener. returns last=true if it is the last call by the limit.
+func (li *ListenerItem) acquire() (ok, last bool) {
+	if li.state.done.Load() {
+		return false, false
+	}
+	if li.limit <= 0 {
+		return true, false
+	}
+
+	n := li.state.calls.Add(1)
+	if n > li.limit {
+		return false, false
+	}
+	if n == li.limit {
+		li.state.done.Store(true)
+		return true, true
+	}
+	return true, false
+}
+
+// finish mark the listener is finished. returns false if it has been finished.
+func (li *ListenerItem) finish() bool {
+	return li.state.done.CompareAndSwap(false, true)
+}
+
+// expired check the ctx of the item is done.
+func (li *ListenerItem) expired() bool {
+	return li.ctx != nil && li.ctx.Err() != nil
 }
 
 /*************************************************************
@@ -55,6 +135,12 @@ type ListenerItem struct {
  *************************************************************/
 
 // ListenerQueue storage sorted Listener instance.
+//
+// Items are ordered by priority(High > Low), items with same priority
+// are ordered by the registration order(FIFO).
+//
+// The queue is copy-on-write: Push, Remove, Sort and Clear always replace
+// the items slice, so a slice got by Items() is an immutable snapshot.
 type ListenerQueue struct {
 	items []*ListenerItem
 }
@@ -69,40 +155,62 @@ func (lq *ListenerQueue) IsEmpty() bool {
 	return len(lq.items) == 0
 }
 
-// Push get items length
+// Push a listener item to the queue, insert it at position by priority.
+//
+// Items with same priority keep the order of push(FIFO).
+//
+// NOTE: the queue is copy-on-write, the items slice returned by Items()
+// before will not be changed.
 func (lq *ListenerQueue) Push(li *ListenerItem) *ListenerQueue {
-	lq.items = append(lq.items, li)
+	n := len(lq.items)
+	// find the first item with lower priority
+	i := sort.Search(n, func(i int) bool {
+		return lq.items[i].Priority < li.Priority
+	})
+
+	items := make([]*ListenerItem, n+1)
+	copy(items, lq.items[:i])
+	items[i] = li
+	copy(items[i+1:], lq.items[i:])
+
+	lq.items = items
 	return lq
 }
 
-// Sort the queue items by ListenerItem's priority.
+// Sort the queue items by ListenerItem's priority. The sort is stable.
+//
+// NOTE: Push always keeps the items sorted, there is no need to call it.
 //
 // Priority:
 //
 //	High > Low
 func (lq *ListenerQueue) Sort() *ListenerQueue {
-	// if lq.IsEmpty() {
-	// 	return lq
-	// }
-	ls := ByPriorityItems(lq.items)
-
 	// check items is sorted
-	if !sort.IsSorted(ls) {
-		sort.Sort(ls)
+	if sort.IsSorted(ByPriorityItems(lq.items)) {
+		return lq
 	}
 
+	// sort on a copy, keep the items snapshot unchanged.
+	ls := ByPriorityItems(copyItems(lq.items))
+	sort.Stable(ls)
+	lq.items = ls
 	return lq
 }
 
-// Items get all ListenerItem
+// Items get all ListenerItem. the returned slice should be treated as read-only.
 func (lq *ListenerQueue) Items() []*ListenerItem {
 	return lq.items
 }
 
 // Remove a listener from the queue
 func (lq *ListenerQueue) Remove(listener Listener) {
+	lq.removeListener(listener)
+}
+
+// removeListener remove a listener from the queue, returns the removed items.
+func (lq *ListenerQueue) removeListener(listener Listener) (removed []*ListenerItem) {
 	if listener == nil {
-		return
+		return nil
 	}
 
 	// unsafe.Pointer(listener)
@@ -112,6 +220,7 @@ func (lq *ListenerQueue) Remove(listener Listener) {
 	for _, li := range lq.items {
 		liPtrVal := getListenCompareKey(li.Listener)
 		if liPtrVal == ptrVal {
+			removed = append(removed, li)
 			continue
 		}
 
@@ -119,11 +228,94 @@ func (lq *ListenerQueue) Remove(listener Listener) {
 	}
 
 	lq.items = newItems
+	return removed
+}
+
+// removeItem remove the item by identity. returns false if not found.
+func (lq *ListenerQueue) removeItem(li *ListenerItem) bool {
+	i := lq.indexOf(li)
+	if i < 0 {
+		return false
+	}
+
+	items := make([]*ListenerItem, 0, len(lq.items)-1)
+	items = append(items, lq.items[:i]...)
+	lq.items = append(items, lq.items[i+1:]...)
+	return true
+}
+
+// indexOf find the index of the item by identity. returns -1 if not found.
+func (lq *ListenerQueue) indexOf(li *ListenerItem) int {
+	for i, item := range lq.items {
+		if item == li {
+			return i
+		}
+	}
+	return -1
+}
+
+// clone the queue, the items snapshot is shared as it is copy-on-write.
+func (lq *ListenerQueue) clone() *ListenerQueue {
+	return &ListenerQueue{items: lq.items}
 }
 
 // Clear all listeners
 func (lq *ListenerQueue) Clear() {
-	lq.items = lq.items[:0]
+	lq.items = nil
+}
+
+// copyItems copy the listener items slice
+func copyItems(items []*ListenerItem) []*ListenerItem {
+	if len(items) == 0 {
+		return nil
+	}
+
+	cp := make([]*ListenerItem, len(items))
+	copy(cp, items)
+	return cp
+}
+
+// mergeItems merge multi sorted listener items groups into one sequence.
+//
+// Items are ordered by priority(High > Low), same priority by registration order.
+// Each group is sorted already, so they are merged by k-way merge without re-sorting.
+//
+// NOTE: the groups slice will be modified, the items snapshots are not changed.
+func mergeItems(groups [][]*ListenerItem) []*ListenerItem {
+	var n, nonEmpty int
+	var last []*ListenerItem
+	for _, items := range groups {
+		if len(items) > 0 {
+			n += len(items)
+			nonEmpty++
+			last = items
+		}
+	}
+	if nonEmpty <= 1 {
+		return last
+	}
+
+	merged := make([]*ListenerItem, 0, n)
+	for len(merged) < n {
+		best := -1
+		for i, items := range groups {
+			if len(items) > 0 && (best < 0 || itemBefore(items[0], groups[best][0])) {
+				best = i
+			}
+		}
+
+		merged = append(merged, groups[best][0])
+		groups[best] = groups[best][1:]
+	}
+	return merged
+}
+
+// itemBefore check the item a should be called before b.
+func itemBefore(a, b *ListenerItem) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.seq < b.seq
 }
 
 // getListenCompareKey get listener compare key
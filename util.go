@@ -4,52 +4,102 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"reflect"
 	"regexp"
+	"runtime"
 	"strings"
 )
 
-// MatchNodePath check for a string.
-//
-// From the gookit/goutil/strutil.MatchNodePath()
+// matchNodePath check the string is matched the pattern.
 //
 // Use on pattern:
-//   - `*` match any to sep
-//   - `**` match any to end. only allow at start or end on pattern.
+//   - `*` match any one node(segment) to sep
+//   - `**` match zero or more nodes in the middle of pattern.
+//     at the start or end of pattern, match one or more nodes.
+//   - `{name}` named param node, match any one node like `*`
+//
+// Example:
+//
+//	"app.**.saved" match "app.saved", "app.user.saved", "app.user.profile.saved"
 func matchNodePath(pattern, s string, sep string) bool {
 	if pattern == Wildcard {
 		return true
 	}
+	return matchNodes(strings.Split(pattern, sep), strings.Split(s, sep), true, nil)
+}
 
-	if i := strings.Index(pattern, AllNode); i >= 0 {
-		if i == 0 { // at start
-			return strings.HasSuffix(s, pattern[2:])
-		}
-		return strings.HasPrefix(s, pattern[:len(pattern)-2])
+// matchNodeParams match the string by pattern, returns the named params captured by `{name}` nodes.
+func matchNodeParams(pattern, s string, sep string) (map[string]string, bool) {
+	params := make(map[string]string)
+	if matchNodes(strings.Split(pattern, sep), strings.Split(s, sep), true, params) {
+		return params, true
 	}
+	return nil, false
+}
 
-	// eg: "eve.some.*.*" -> match "eve.some.thing.run" "eve.some.thing.do"
-	pattern = strings.Replace(pattern, sep, "/", -1)
-	s = strings.Replace(s, sep, "/", -1)
-	ok, err := path.Match(pattern, s)
-	if err != nil {
-		ok = false
+// matchNodes match the string nodes by pattern nodes. leading is true on start of pattern.
+//
+// if params is not nil, will collect the named params to it.
+func matchNodes(ps, ss []string, leading bool, params map[string]string) bool {
+	for len(ps) > 0 {
+		p := ps[0]
+		if p == AllNode {
+			rest := ps[1:]
+			least := 0
+			if leading || len(rest) == 0 {
+				least = 1
+			}
+
+			for i := least; i <= len(ss); i++ {
+				if matchNodes(rest, ss[i:], false, params) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(ss) == 0 {
+			return false
+		}
+
+		if key, ok := paramNodeName(p); ok {
+			if params != nil {
+				params[key] = ss[0]
+			}
+		} else if p != ss[0] {
+			if ok, err := path.Match(p, ss[0]); err != nil || !ok {
+				return false
+			}
+		}
+
+		ps, ss = ps[1:], ss[1:]
+		leading = false
 	}
-	return ok
+	return len(ss) == 0
 }
 
 // regex for check good event name.
 var goodNameReg = regexp.MustCompile(`^[a-zA-Z][\w-.*]*$`)
 
-// goodName check event name is valid.
-func goodName(name string, isReg bool) string {
-	name, err := goodNameOrErr(name, isReg)
-	if err != nil {
-		panic(err)
+// regex for check the pattern chars, use on start with AllNode. eg: "**.add"
+var goodPatternReg = regexp.MustCompile(`^[\w-.*]+$`)
+
+// regex for match named param node. eg: "{tenant}"
+var paramNodeReg = regexp.MustCompile(`^\{([a-zA-Z_]\w*)\}$`)
+
+// paramNodeName get the param name if the node is a named param node. eg: "{tenant}" -> "tenant"
+func paramNodeName(node string) (string, bool) {
+	if len(node) < 3 || node[0] != '{' {
+		return "", false
 	}
-	return name
+
+	if ss := paramNodeReg.FindStringSubmatch(node); len(ss) == 2 {
+		return ss[1], true
+	}
+	return "", false
 }
 
-// goodNameOrErr check event name is valid.
+// goodNameOrErr check event name is valid. use for ModeSimple
 func goodNameOrErr(name string, isReg bool) (string, error) {
 	name = strings.TrimSpace(name)
 	if name == "" {
@@ -61,6 +111,10 @@ func goodNameOrErr(name string, isReg bool) (string, error) {
 		if name == AllNode || name == Wildcard {
 			return Wildcard, nil
 		}
+		// named params can never match on ModeSimple
+		if strings.ContainsAny(name, "{}") {
+			return name, fmt.Errorf("event: pattern %q is invalid, named param is only supported on ModePath", name)
+		}
 		if strings.HasPrefix(name, AllNode) {
 			return name, nil
 		}
@@ -72,6 +126,71 @@ func goodNameOrErr(name string, isReg bool) (string, error) {
 	return name, nil
 }
 
+// goodPatternOrErr check event name or listen pattern is valid. use for ModePath
+//
+// The pattern nodes are checked, and the named param nodes are allowed. see checkPatternNodes()
+func goodPatternOrErr(name string, isReg bool) (string, error) {
+	name = strings.TrimSpace(name)
+	if !isReg || name == AllNode || name == Wildcard {
+		return goodNameOrErr(name, isReg)
+	}
+
+	chkName, err := checkPatternNodes(name)
+	if err != nil {
+		return name, err
+	}
+
+	// AllNode at start. eg: "**.add"
+	if strings.HasPrefix(chkName, AllNode) {
+		if !goodPatternReg.MatchString(chkName) {
+			return name, errors.New(`event: pattern is invalid, must match regex:` + goodPatternReg.String())
+		}
+		return name, nil
+	}
+
+	if !goodNameReg.MatchString(chkName) {
+		return name, errors.New(`event: name is invalid, must match regex:` + goodNameReg.String())
+	}
+	return name, nil
+}
+
+// checkPatternNodes check each node of the listen pattern is valid.
+// returns the pattern with named param nodes replaced by AnyNode, for check the chars.
+//
+//   - node cannot be empty. eg: "app..saved", "app."
+//   - AllNode "**" must be a whole node. eg: "app.**x", "app.***"
+//   - named param node must be like "{name}". eg: "app.{id", "app.x{id}"
+func checkPatternNodes(pattern string) (string, error) {
+	nodes := strings.Split(pattern, ".")
+	for i, node := range nodes {
+		if node == "" {
+			return pattern, fmt.Errorf("event: pattern %q is invalid, contains empty node", pattern)
+		}
+		if node != AllNode && strings.Contains(node, AllNode) {
+			return pattern, fmt.Errorf("event: pattern %q is invalid, %q must be a whole node", pattern, AllNode)
+		}
+
+		if strings.ContainsAny(node, "{}") {
+			if _, ok := paramNodeName(node); !ok {
+				return pattern, fmt.Errorf("event: pattern %q is invalid, named param must be a whole node like {name}", pattern)
+			}
+			nodes[i] = AnyNode
+		}
+	}
+	return strings.Join(nodes, "."), nil
+}
+
+// listenerName get the listener identity. func name for func listener, otherwise the type name.
+func listenerName(listener Listener) string {
+	rv := reflect.ValueOf(listener)
+	if rv.Kind() == reflect.Func {
+		if fn := runtime.FuncForPC(rv.Pointer()); fn != nil {
+			return fn.Name()
+		}
+	}
+	return fmt.Sprintf("%T", listener)
+}
+
 func panicf(format string, args ...any) {
 	panic(fmt.Sprintf(format, args...))
 }
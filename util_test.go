@@ -0,0 +1,112 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/gookit/goutil/testutil/assert"
+)
+
+func TestMatchNodePath(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*", "any.name", true},
+		{"db.user.*", "db.user.add", true},
+		{"db.user.*", "db.user", false},
+		{"db.*.update", "db.user.update", true},
+		{"db.us*.del", "db.user.del", true},
+		{"db.**", "db.user.add", true},
+		{"db.**", "db", false},
+		{"**.add", "db.user.add", true},
+		{"**.add", "add", false},
+		{"app.**.saved", "app.saved", true},
+		{"app.**.saved", "app.user.saved", true},
+		{"app.**.saved", "app.user.profile.saved", true},
+		{"app.**.saved", "app.user.profile", false},
+		{"app.**.saved", "other.user.saved", false},
+		{"app.**.user.**.saved", "app.user.saved", true},
+		{"app.**.user.**.saved", "app.org.user.profile.saved", true},
+		{"app.**.user.**.saved", "app.org.profile.saved", false},
+		{"**.user.**", "app.user.saved", true},
+		{"**.user.**", "user.saved", false},
+	}
+
+	for _, tt := range tests {
+		assert.Eq(t, tt.want, matchNodePath(tt.pattern, tt.name, "."), tt.pattern+" <=> "+tt.name)
+	}
+
+	// named params
+	params, ok := matchNodeParams("tenant.{tenant}.**.{action}", "tenant.t1.order.item.paid", ".")
+	assert.True(t, ok)
+	assert.Eq(t, map[string]string{"tenant": "t1", "action": "paid"}, params)
+
+	params, ok = matchNodeParams("tenant.{tenant}.order", "tenant.t1.user", ".")
+	assert.False(t, ok)
+	assert.Nil(t, params)
+}
+
+func TestGoodNameOrErr(t *testing.T) {
+	// ModeSimple keeps the loose check
+	for _, name := range []string{"app.*", "**.add", "app..saved", "app."} {
+		_, err := goodNameOrErr(name, true)
+		assert.NoErr(t, err, name)
+	}
+
+	_, err := goodNameOrErr("1app", true)
+	assert.Err(t, err)
+
+	// named params can never match on ModeSimple
+	for _, name := range []string{"tenant.{id}", "**.{id}"} {
+		_, err = goodNameOrErr(name, true)
+		assert.ErrSubMsg(t, err, "only supported on ModePath", name)
+	}
+}
+
+func TestGoodPatternOrErr(t *testing.T) {
+	// valid patterns
+	for _, name := range []string{"app.*", "app.**", "**.add", "app.**.saved", "a.**.b.**.c", "app.us*.add", " app.evt1 ", "tenant.{tenant}.order.{action}"} {
+		_, err := goodPatternOrErr(name, true)
+		assert.NoErr(t, err, name)
+	}
+
+	name, err := goodPatternOrErr("**", true)
+	assert.NoErr(t, err)
+	assert.Eq(t, Wildcard, name)
+
+	// malformed patterns
+	for _, name := range []string{"app.**x", "app.***", "app.x**.saved", "app..saved", "app.", "**..add", "**.a#b", "1app"} {
+		_, err = goodPatternOrErr(name, true)
+		assert.Err(t, err, name)
+	}
+
+	// fire event name
+	_, err = goodPatternOrErr("**.add", false)
+	assert.Err(t, err)
+	_, err = goodPatternOrErr("", false)
+	assert.Err(t, err)
+}
+
+func TestMergeItems(t *testing.T) {
+	item := func(priority int, seq uint64) *ListenerItem {
+		return &ListenerItem{Priority: priority, seq: seq}
+	}
+
+	g1 := []*ListenerItem{item(High, 3), item(Normal, 1), item(Normal, 5), item(Low, 2)}
+	g2 := []*ListenerItem{item(Max, 6), item(Normal, 4)}
+	g3 := []*ListenerItem{item(Normal, 2)}
+
+	merged := mergeItems([][]*ListenerItem{g1, nil, g2, g3})
+	var got []uint64
+	for _, li := range merged {
+		got = append(got, li.seq)
+	}
+	assert.Eq(t, []uint64{6, 3, 1, 2, 4, 5, 2}, got)
+	// the snapshots are not changed
+	assert.Len(t, g1, 4)
+	assert.Eq(t, uint64(3), g1[0].seq)
+
+	// single non-empty group is returned directly
+	assert.Eq(t, g3, mergeItems([][]*ListenerItem{nil, g3}))
+	assert.Nil(t, mergeItems(nil))
+}